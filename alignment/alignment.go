@@ -0,0 +1,175 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package alignment
+
+import (
+	"math"
+	"sync"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+type (
+	AlConfig struct {
+		ToneBinary string  // eg. "/usr/bin/aplay", empty for no tone
+		ToneDevice string  // ALSA device, empty for the default
+		ToneLowHz  float64 // pitch with no signal, zero for the default
+		ToneHighHz float64 // pitch with the best signal, zero for the default
+	}
+	// A single value with its peak, and both as a level from 0.0 to 1.0
+	Reading struct {
+		Value     float64 // NaN when not known
+		Peak      float64 // NaN until a value is known
+		Level     float64
+		PeakLevel float64
+	}
+	// The alignment readings
+	Meter struct {
+		Mer     Reading // dB, only while locked
+		Power   Reading // dBm from the AGC
+		Beacon  Reading // dB above the noise floor from the spectrum
+		Quality float64 // from 0.0 to 1.0, drives the tone
+	}
+)
+
+func Intitialize(cfg AlConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	alCfg = cfg
+	if alCfg.ToneLowHz <= 0 {
+		alCfg.ToneLowHz = kDefaultToneLowHz
+	}
+	if alCfg.ToneHighHz <= alCfg.ToneLowHz {
+		alCfg.ToneHighHz = max(kDefaultToneHighHz, alCfg.ToneLowHz*2)
+	}
+	resetMeter()
+}
+
+// Starts alignment with the peaks reset, and the tone if enabled
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+	if active {
+		return
+	}
+	active = true
+	resetMeter()
+	if toneEnabled {
+		startTone()
+	}
+	qLog.Info("Alignment has started")
+}
+
+// Stops alignment and the tone
+func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	if !active {
+		return
+	}
+	active = false
+	stopTone()
+	qLog.Info("Alignment has stopped")
+}
+
+// Updates the meter with the latest values, NaN when not known, and returns a copy
+func Update(mer, power, beaconSnr float64) Meter {
+	mu.Lock()
+	defer mu.Unlock()
+	meter.Mer.update(mer, kMerMin, kMerMax)
+	meter.Power.update(power, kPowerMin, kPowerMax)
+	meter.Beacon.update(beaconSnr, kBeaconMin, kBeaconMax)
+	meter.Quality = quality()
+	pitch = alCfg.ToneLowHz + meter.Quality*(alCfg.ToneHighHz-alCfg.ToneLowHz)
+	return meter
+}
+
+// Clears the peak-hold markers
+func ResetPeaks() {
+	mu.Lock()
+	defer mu.Unlock()
+	resetMeter()
+}
+
+// Turns the tone on or off and returns true if it is now on
+func ToggleTone() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	toneEnabled = !toneEnabled
+	if active {
+		if toneEnabled {
+			startTone()
+		} else {
+			stopTone()
+		}
+	}
+	return toneEnabled
+}
+
+// Returns true if the tone is enabled
+func ToneEnabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return toneEnabled
+}
+
+// END API ****************************************************
+
+const (
+	kMerMin, kMerMax       = 0.0, 20.0
+	kPowerMin, kPowerMax   = -100.0, -35.0
+	kBeaconMin, kBeaconMax = 0.0, 20.0
+	kDefaultToneLowHz      = 300.0
+	kDefaultToneHighHz     = 1500.0
+)
+
+// mu guards everything below, and the tone
+var (
+	mu          sync.Mutex
+	alCfg       AlConfig
+	active      bool
+	toneEnabled bool
+	meter       Meter
+	pitch       float64
+)
+
+// Must be called with mu held
+func resetMeter() {
+	unknown := Reading{Value: math.NaN(), Peak: math.NaN()}
+	meter = Meter{Mer: unknown, Power: unknown, Beacon: unknown}
+	pitch = alCfg.ToneLowHz
+}
+
+// Sets the value, raising the peak if it is higher
+func (r *Reading) update(value, vMin, vMax float64) {
+	r.Value = value
+	if math.IsNaN(value) {
+		r.Level = 0
+		return
+	}
+	r.Level = toLevel(value, vMin, vMax)
+	if math.IsNaN(r.Peak) || value > r.Peak {
+		r.Peak = value
+		r.PeakLevel = r.Level
+	}
+}
+
+// Returns the level of value between vMin and vMax from 0.0 to 1.0
+func toLevel(value, vMin, vMax float64) float64 {
+	return min(max((value-vMin)/(vMax-vMin), 0), 1)
+}
+
+// Returns the signal quality. Must be called with mu held
+//
+//	the beacon drives the bottom half until longmynd locks, then MER drives the top half
+func quality() float64 {
+	if !math.IsNaN(meter.Mer.Value) {
+		return 0.5 + meter.Mer.Level/2
+	}
+	return meter.Beacon.Level / 2
+}
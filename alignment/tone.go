@@ -0,0 +1,105 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package alignment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// the pitch follows the meter within about kToneLead plus aplay's buffer time
+const (
+	kToneRate      = 8000 // samples per second
+	kToneChunk     = 200  // samples per write, 25ms
+	kToneLead      = 2    // chunks written ahead of the sound card, so it does not run dry
+	kToneAmplitude = 8000
+	kToneBuffer    = 50000 // µs of aplay's buffer
+	kTonePeriod    = 25000 // µs
+)
+
+// guarded by mu
+var (
+	toneCmd  *exec.Cmd
+	toneStop chan struct{}
+)
+
+// Starts aplay and a go routine to feed it. Must be called with mu held
+func startTone() {
+	if toneCmd != nil || alCfg.ToneBinary == "" {
+		return
+	}
+	args := []string{"-q", "-t", "raw", "-f", "S16_LE", "-r", fmt.Sprint(kToneRate), "-c", "1",
+		"--buffer-time", fmt.Sprint(kToneBuffer), "--period-time", fmt.Sprint(kTonePeriod)}
+	if alCfg.ToneDevice != "" {
+		args = append(args, "-D", alCfg.ToneDevice)
+	}
+	cmd := exec.Command(alCfg.ToneBinary, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		qLog.Error("Failed to pipe to the tone: %v", err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		qLog.Error("Failed to start the tone: %v", err)
+		return
+	}
+	toneCmd = cmd
+	toneStop = make(chan struct{})
+	go writeTone(stdin, toneStop)
+}
+
+// Stops the tone. Must be called with mu held
+func stopTone() {
+	if toneCmd == nil {
+		return
+	}
+	close(toneStop)
+	toneCmd.Process.Kill()
+	toneCmd.Wait()
+	toneCmd = nil
+}
+
+// Writes a sine wave at the current pitch until stopped
+//
+//	a chunk at a time, at the sample rate, so that little is queued in the pipe and aplay
+func writeTone(w io.WriteCloser, stop chan struct{}) {
+	defer w.Close()
+	buf := make([]byte, kToneChunk*2)
+	var phase float64
+	ticker := time.NewTicker(time.Second * kToneChunk / kToneRate)
+	defer ticker.Stop()
+	for written := 0; ; written++ {
+		if written >= kToneLead {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+		mu.Lock()
+		hz := pitch
+		mu.Unlock()
+		for i := 0; i < kToneChunk; i++ {
+			binary.LittleEndian.PutUint16(buf[i*2:], uint16(int16(kToneAmplitude*math.Sin(phase))))
+			phase += 2 * math.Pi * hz / kToneRate
+		}
+		phase = math.Mod(phase, 2*math.Pi)
+		if _, err := w.Write(buf); err != nil {
+			select {
+			case <-stop:
+			default:
+				qLog.Warn("The tone has stopped: %v", err)
+			}
+			return
+		}
+	}
+}
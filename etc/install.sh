@@ -158,7 +158,7 @@ INSTALL HAS COMPLETED
     
     cd Q100/q100receiver-bookworm
     go mod tidy
-    go build .
+    go build -ldflags "-X main.version=$(git describe --tags --always)" .
  
 "
 
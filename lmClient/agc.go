@@ -0,0 +1,263 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ********************************************************
+
+type (
+	// A point in an AGC calibration table
+	AgcPoint struct {
+		Agc int
+		Dbm float64
+	}
+	// Converts the AGC1 and AGC2 gains reported by longmynd to dBm
+	//
+	//	tables are kept in order of Agc, and values between points are interpolated
+	AgcCalibration struct {
+		Agc1     []AgcPoint // used while AGC1 is above zero
+		Agc2     []AgcPoint // used when AGC1 is zero
+		OffsetDb float64    // added to every result, see CalibratePower
+	}
+)
+
+// Returns the built-in calibration, measured on a single MiniTiouner
+func DefaultAgcCalibration() AgcCalibration {
+	c := AgcCalibration{}
+	for _, n := range kAgc1 {
+		c.Agc1 = append(c.Agc1, AgcPoint{Agc: n[0], Dbm: float64(n[1])})
+	}
+	for _, n := range kAgc2 {
+		c.Agc2 = append(c.Agc2, AgcPoint{Agc: n[0], Dbm: float64(n[1])})
+	}
+	c.normalize()
+	return c
+}
+
+// Reads a calibration from a file of lines like these, with # for comments
+//
+//	agc1 21800 -68
+//	agc2 182 -71
+//	offset 1.5
+func LoadAgcCalibration(path string) (AgcCalibration, error) {
+	c := AgcCalibration{}
+	file, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := c.parse(fields); err != nil {
+			return c, fmt.Errorf("%v line %v: %v", path, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return c, err
+	}
+	if len(c.Agc1) == 0 || len(c.Agc2) == 0 {
+		return c, fmt.Errorf("%v: needs both agc1 and agc2 points", path)
+	}
+	c.normalize()
+	return c, nil
+}
+
+// Writes the calibration in the format read by LoadAgcCalibration
+func (c AgcCalibration) Save(path string) error {
+	var sb strings.Builder
+	sb.WriteString("# AGC calibration: agc1|agc2 <gain> <dBm> and offset <dB>\n")
+	fmt.Fprintf(&sb, "offset %v\n", c.OffsetDb)
+	for _, p := range c.Agc1 {
+		fmt.Fprintf(&sb, "agc1 %v %v\n", p.Agc, p.Dbm)
+	}
+	for _, p := range c.Agc2 {
+		fmt.Fprintf(&sb, "agc2 %v %v\n", p.Agc, p.Dbm)
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// Returns the power in dBm for a pair of AGC gains
+func (c AgcCalibration) Dbm(agc1, agc2 int) float64 {
+	if agc1 > 0 {
+		return interpolate(c.Agc1, agc1) + c.OffsetDb
+	}
+	return interpolate(c.Agc2, agc2) + c.OffsetDb
+}
+
+// Returns a copy of the calibration in use
+func PowerCalibration() AgcCalibration {
+	agcMu.Lock()
+	defer agcMu.Unlock()
+	return agcCal
+}
+
+// Calibrates the power against a signal of known level, eg. from a signal generator
+//
+//	sets the offset so the latest AGC gains read as knownDbm and saves the
+//	calibration to LmConfig.AgcFile. Refused unless tuned and locked to the signal
+func CalibratePower(knownDbm float64) error {
+	if !IsTuned() {
+		return errors.New("not tuned")
+	}
+	agcMu.Lock()
+	if !agcLocked {
+		agcMu.Unlock()
+		return errors.New("not locked")
+	}
+	if !agcValid {
+		agcMu.Unlock()
+		return errors.New("no AGC gains received")
+	}
+	agcCal.OffsetDb = 0
+	agcCal.OffsetDb = knownDbm - agcCal.Dbm(lastAgc1, lastAgc2)
+	cal := agcCal
+	agcMu.Unlock()
+	qLog.Info("Power calibrated to %.1f dBm, offset %.1f dB", knownDbm, cal.OffsetDb)
+	if lmcfg.AgcFile == "" {
+		return nil
+	}
+	return cal.Save(lmcfg.AgcFile)
+}
+
+// END API ********************************************************
+
+// agcMu guards the calibration and the latest gains
+var (
+	agcMu     sync.Mutex
+	agcCal    = DefaultAgcCalibration()
+	lastAgc1  int
+	lastAgc2  int
+	agcValid  bool // the latest gains were received while locked
+	agcLocked bool
+)
+
+// Parses one line of a calibration file
+func (c *AgcCalibration) parse(fields []string) error {
+	switch {
+	case fields[0] == "offset" && len(fields) == 2:
+		offset, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return err
+		}
+		c.OffsetDb = offset
+	case (fields[0] == "agc1" || fields[0] == "agc2") && len(fields) == 3:
+		agc, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return err
+		}
+		dbm, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return err
+		}
+		if fields[0] == "agc1" {
+			c.Agc1 = append(c.Agc1, AgcPoint{Agc: agc, Dbm: dbm})
+		} else {
+			c.Agc2 = append(c.Agc2, AgcPoint{Agc: agc, Dbm: dbm})
+		}
+	default:
+		return fmt.Errorf("unexpected %q", strings.Join(fields, " "))
+	}
+	return nil
+}
+
+// Sorts both tables by Agc and merges points with the same Agc
+func (c *AgcCalibration) normalize() {
+	c.Agc1 = normalizePoints(c.Agc1)
+	c.Agc2 = normalizePoints(c.Agc2)
+}
+
+func normalizePoints(points []AgcPoint) []AgcPoint {
+	slices.SortStableFunc(points, func(a, b AgcPoint) int {
+		return a.Agc - b.Agc
+	})
+	merged := points[:0]
+	count := 1
+	for _, p := range points {
+		if n := len(merged); n > 0 && merged[n-1].Agc == p.Agc {
+			// average the duplicates
+			merged[n-1].Dbm = (merged[n-1].Dbm*float64(count) + p.Dbm) / float64(count+1)
+			count++
+			continue
+		}
+		merged = append(merged, p)
+		count = 1
+	}
+	return merged
+}
+
+// Returns the dBm at agc, interpolated between points and clamped at both ends
+func interpolate(points []AgcPoint, agc int) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	i, _ := slices.BinarySearchFunc(points, agc, func(p AgcPoint, agc int) int {
+		return p.Agc - agc
+	})
+	switch {
+	case i == 0:
+		return points[0].Dbm
+	case i == len(points):
+		return points[len(points)-1].Dbm
+	}
+	lo, hi := points[i-1], points[i]
+	return lo.Dbm + (hi.Dbm-lo.Dbm)*float64(agc-lo.Agc)/float64(hi.Agc-lo.Agc)
+}
+
+// Replaces the built-in calibration with LmConfig.AgcFile, if it exists
+func loadAgcCalibration() {
+	if lmcfg.AgcFile == "" {
+		return
+	}
+	cal, err := LoadAgcCalibration(lmcfg.AgcFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			qLog.Warn("Failed to load the AGC calibration: %v", err)
+		}
+		return
+	}
+	qLog.Info("AGC calibration loaded from %v", lmcfg.AgcFile)
+	agcMu.Lock()
+	defer agcMu.Unlock()
+	agcCal = cal
+}
+
+// Records whether the decoder is locked, forgetting the latest gains when it is not
+func setAgcLocked(locked bool) {
+	agcMu.Lock()
+	defer agcMu.Unlock()
+	agcLocked = locked
+	if !locked {
+		agcValid = false
+	}
+}
+
+// Returns the power in dBm for the AGC gains, and saves them for CalibratePower while locked
+func agcToDbm(agc1, agc2 int) float64 {
+	agcMu.Lock()
+	defer agcMu.Unlock()
+	if agcLocked {
+		lastAgc1, lastAgc2, agcValid = agc1, agc2, true
+	}
+	return agcCal.Dbm(agc1, agc2)
+}
@@ -0,0 +1,61 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	points := []AgcPoint{{100, -60}, {200, -70}, {400, -80}}
+	tests := []struct {
+		agc  int
+		want float64
+	}{
+		{0, -60},   // below the table
+		{100, -60}, // first point
+		{150, -65},
+		{200, -70},
+		{300, -75},
+		{400, -80},   // last point
+		{65535, -80}, // above the table
+	}
+	for _, tt := range tests {
+		if got := interpolate(points, tt.agc); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("interpolate(%v) = %v, want %v", tt.agc, got, tt.want)
+		}
+	}
+	if got := interpolate(nil, 100); got != 0 {
+		t.Errorf("interpolate of no points = %v, want 0", got)
+	}
+}
+
+func TestAgcCalibrationDbm(t *testing.T) {
+	c := DefaultAgcCalibration()
+	first1, last1 := c.Agc1[0], c.Agc1[len(c.Agc1)-1]
+	first2, last2 := c.Agc2[0], c.Agc2[len(c.Agc2)-1]
+	tests := []struct {
+		name       string
+		agc1, agc2 int
+		offset     float64
+		want       float64
+	}{
+		{"agc1 first", first1.Agc, 0, 0, first1.Dbm},
+		{"agc1 last", last1.Agc, 0, 0, last1.Dbm},
+		{"agc1 too strong", 65535, 0, 0, last1.Dbm},
+		{"agc2 when agc1 is zero", 0, first2.Agc, 0, first2.Dbm},
+		{"agc2 below the table", 0, 0, 0, first2.Dbm},
+		{"agc2 above the table", 0, 65535, 0, last2.Dbm},
+		{"offset", first1.Agc, 0, 1.5, first1.Dbm + 1.5},
+	}
+	for _, tt := range tests {
+		c.OffsetDb = tt.offset
+		if got := c.Dbm(tt.agc1, tt.agc2); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%v: Dbm(%v, %v) = %v, want %v", tt.name, tt.agc1, tt.agc2, got, tt.want)
+		}
+	}
+}
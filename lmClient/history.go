@@ -0,0 +1,106 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// BEGIN API ********************************************************
+
+// One sample of the signal history. Values are NaN when not known, eg. when not locked
+type SignalSample struct {
+	Time   time.Time
+	Mer    float64 // dB
+	Margin float64 // dB
+	Power  float64 // dBm
+}
+
+// Returns a copy of the signal history, oldest first, and its capacity
+func SignalHistory() ([]SignalSample, int) {
+	histMu.Lock()
+	defer histMu.Unlock()
+	samples := make([]SignalSample, 0, histCount)
+	for i := 0; i < histCount; i++ {
+		samples = append(samples, histSamples[(histNext-histCount+i+len(histSamples))%len(histSamples)])
+	}
+	return samples, len(histSamples)
+}
+
+// Returns the latest values, NaN when not known
+func LatestSignal() SignalSample {
+	histMu.Lock()
+	defer histMu.Unlock()
+	return latest
+}
+
+// END API ********************************************************
+
+const (
+	kDefaultHistoryInterval = time.Second
+	kDefaultHistoryLength   = 10 * time.Minute
+)
+
+// histMu guards the latest values and the history
+var (
+	histMu      sync.Mutex
+	latest      = SignalSample{Mer: math.NaN(), Margin: math.NaN(), Power: math.NaN()}
+	histSamples []SignalSample
+	histNext    int // index of the next sample
+	histCount   int // number of valid samples
+)
+
+// Samples the latest values forever, at LmConfig.HistoryInterval
+func sampleHistory() {
+	interval := lmcfg.HistoryInterval
+	if interval <= 0 {
+		interval = kDefaultHistoryInterval
+	}
+	length := lmcfg.HistoryLength
+	if length <= 0 {
+		length = kDefaultHistoryLength
+	}
+	histMu.Lock()
+	histSamples = make([]SignalSample, max(int(length/interval), 2))
+	histMu.Unlock()
+
+	for now := range time.Tick(interval) {
+		histMu.Lock()
+		sample := latest
+		sample.Time = now
+		histSamples[histNext] = sample
+		histNext = (histNext + 1) % len(histSamples)
+		histCount = min(histCount+1, len(histSamples))
+		histMu.Unlock()
+	}
+}
+
+func setLatestMer(mer float64) {
+	histMu.Lock()
+	defer histMu.Unlock()
+	latest.Mer = mer
+}
+
+func setLatestMargin(margin float64) {
+	histMu.Lock()
+	defer histMu.Unlock()
+	latest.Margin = margin
+}
+
+func setLatestPower(power float64) {
+	histMu.Lock()
+	defer histMu.Unlock()
+	latest.Power = power
+}
+
+// Called whenever the decoded values are reset
+func clearLatest() {
+	histMu.Lock()
+	defer histMu.Unlock()
+	latest = SignalSample{Mer: math.NaN(), Margin: math.NaN(), Power: math.NaN()}
+}
@@ -11,8 +11,11 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ea7kir/qLog"
 )
@@ -29,8 +32,9 @@ type LongmyndData struct {
 	Provider      string
 	Service       string
 	NullRatio     string
-	PidPair1      string
+	PidPair1      string // the first two elementary streams, see ElementaryStreams for all of them
 	PidPair2      string
+	EsCount       int
 	VideoCodec    string
 	AudioCodec    string
 	Constellation string
@@ -38,21 +42,40 @@ type LongmyndData struct {
 	Mode          string
 	DbMargin      string
 	DbmPower      string
+	Lnb           string // as reported by longmynd: LnbStatusOff, LnbStatus13V or LnbStatus18V
+	LnbMismatch   bool   // true when the reported Lnb differs from the requested LmOptions.Lnb
+	ShortFrames   bool
+	Pilots        bool
+	Margin        float64     // dB, valid unless MarginState is MarginUnknown
+	MarginState   MarginState // for colouring DbMargin
 }
 
 type (
 	LmConfig struct {
 		Folder     string
 		Binary     string
-		Offset     float64
+		Offset     float64 // KHz, replaced by the calibrated offset when OffsetFile exists
+		OffsetFile string  // where the calibrated offset is saved
+		AgcFile    string  // per-device AGC calibration, see LoadAgcCalibration
 		StatusFifo string
+		Options    LmOptions // used when a band has no options of its own
+		// signal history, zero for the defaults
+		HistoryInterval time.Duration
+		HistoryLength   time.Duration
 		// StartScript string
 		// StopScript  string
 	}
 	FpConfig struct {
-		Binary string
-		TsFifo string
-		Volume string
+		Player      string // PlayerFfplay, PlayerMpv or PlayerVlc
+		Binary      string // empty for the player's default
+		TsFifo      string
+		Volume      string          // 0 to 100
+		AudioDevice string          // player specific, empty for the default device
+		Left, Top   int             // window position in pixels
+		Width       int             // zero for fullscreen
+		Height      int             // zero for fullscreen
+		Embed       bool            // show video in the region of the Gio window set by SetVideoRegion
+		Template    *PlayerTemplate // overrides the built-in template for Player
 		// StartScript string
 		// StopScript  string
 	}
@@ -62,26 +85,86 @@ func Intitialize(lmc LmConfig, fpc FpConfig, ch chan LongmyndData) {
 	lmcfg = lmc
 	fpcfg = fpc
 	lmChannel = ch
-	// stopFfPlayAndLongmynd()
-	go readLongmynd(lmcfg.StatusFifo, lmcfg.Offset, lmChannel)
+	loadOffset()
+	loadAgcCalibration()
+	go sampleHistory()
+	// stopPlayerAndLongmynd()
+	go readLongmynd(lmcfg.StatusFifo, lmChannel)
 }
 
 func Stop() {
 	qLog.Info("LmReader will stop... - NOT IMPLEMENTED")
 	// TODO: implement a better way to stop longmynd and ffplay
-	stopFfPlayAndLongmynd()
+	procMu.Lock()
+	defer procMu.Unlock()
+	stopPlayerAndLongmynd()
 	qLog.Info("LmReader has stopped")
 }
 
-func Tune(frequency, sysmbolRate string) {
+func Tune(frequency, sysmbolRate string, opts LmOptions) {
 	qLog.Info("------ WILL TUNE")
-	startLongmynd(frequency, sysmbolRate) // TODO: pass arguments
+	procMu.Lock()
+	defer procMu.Unlock()
+	startLongmynd(frequency, sysmbolRate, opts)
 }
 
 func UnTune() {
 	qLog.Info("------ WILL UNTUNE")
-	stopFfPlayAndLongmynd()
+	setAgcLocked(false)
+	procMu.Lock()
+	defer procMu.Unlock()
+	stopPlayerAndLongmynd()
+}
+
+// Returns true while longmynd is running
+func IsTuned() bool {
+	procMu.Lock()
+	defer procMu.Unlock()
+	return isTuned
+}
+
+// A program started by lmClient
+type Process struct {
+	Binary      string
+	VersionArgs []string // print its version, empty if unknown
+	Pid         int      // zero when not running
+}
+
+// Returns the longmynd and video player processes
+func Processes() (longmynd Process, player Process) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	longmynd.Binary = lmcfg.Binary
+	if isTuned && lmCmd != nil && lmCmd.Process != nil {
+		longmynd.Pid = lmCmd.Process.Pid
+	}
+	tmpl := playerTemplate()
+	player.Binary = fpcfg.Binary
+	if player.Binary == "" {
+		player.Binary = tmpl.Binary
+	}
+	player.VersionArgs = tmpl.Version
+	if isPlaying && recordPath == "" && playerCmd != nil && playerCmd.Process != nil {
+		player.Pid = playerCmd.Process.Pid
+	}
+	return longmynd, player
+}
+
+// Sets a function to be called from the decoder whenever the lock state changes
+//
+//	the function is called from the decoder go routine
+func OnLockChange(fn func(locked bool)) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	lockChanged = fn
+}
+
+// Shown in LongmyndData for any value that is not known
+const NoValue = kDash
 
+// Returns true if the data is from a locked signal
+func (p LongmyndData) IsLocked() bool {
+	return p.State == kLocked
 }
 
 // END API ********************************************************
@@ -89,6 +172,8 @@ func UnTune() {
 func (p *LongmyndData) reset() {
 	p.resetPartial()
 	p.State = kDash
+	p.Lnb = kDash
+	p.LnbMismatch = false
 }
 
 func (p *LongmyndData) resetPartial() {
@@ -102,6 +187,7 @@ func (p *LongmyndData) resetPartial() {
 	p.NullRatio = kDash
 	p.PidPair1 = kDash
 	p.PidPair2 = kDash
+	p.EsCount = 0
 	p.VideoCodec = kDash
 	p.AudioCodec = kDash
 	p.Constellation = kDash
@@ -109,15 +195,18 @@ func (p *LongmyndData) resetPartial() {
 	p.Mode = kDash
 	p.DbMargin = kDash
 	p.DbmPower = kDash
+	p.ShortFrames = false
+	p.Pilots = false
+	p.Margin = 0
+	p.MarginState = MarginUnknown
 }
 
 var (
-	lmcfg          LmConfig
-	fpcfg          FpConfig
-	lmChannel      chan LongmyndData
-	lmCmd          *exec.Cmd
-	ffPlayCmd      *exec.Cmd
-	ffPlayIsACtive bool // TODO: temp fix to prevent more than one ffplay instance
+	lmcfg        LmConfig
+	fpcfg        FpConfig
+	lmChannel    chan LongmyndData
+	lmCmd        *exec.Cmd
+	tunedOptions LmOptions
 )
 
 type (
@@ -144,41 +233,67 @@ var (
 		{"32APSK", "5/6"}, {"32APSK", "8/9"}, {"32APSK", "9/10"},
 	}
 
-	kModeFecThreshold = map[string]float64{
-		"DVB-S 1/2":          1.7,
-		"DVB-S 2/3":          3.3,
-		"DVB-S 3/4":          4.2,
-		"DVB-S 5/6":          5.1,
-		"DVB-S 6/7":          5.5,
-		"DVB-S 7/8":          5.8,
-		"DVB-S2 QPSK 1/4":    -2.3,
-		"DVB-S2 QPSK 1/3":    -1.2,
-		"DVB-S2 QPSK 2/5":    -0.3,
-		"DVB-S2 QPSK 1/2":    1.0,
-		"DVB-S2 QPSK 3/5":    2.3,
-		"DVB-S2 QPSK 2/3":    3.1,
-		"DVB-S2 QPSK 3/4":    4.1,
-		"DVB-S2 QPSK 4/5":    4.7,
-		"DVB-S2 QPSK 5/6":    5.2,
-		"DVB-S2 QPSK 8/9":    6.2,
-		"DVB-S2 QPSK 9/10":   6.5,
-		"DVB-S2 8PSK 3/5":    5.5,
-		"DVB-S2 8PSK 2/3":    6.6,
-		"DVB-S2 8PSK 3/4":    7.9,
-		"DVB-S2 8PSK 5/6":    9.4,
-		"DVB-S2 8PSK 8/9":    10.7,
-		"DVB-S2 8PSK 9/10":   11.0,
-		"DVB-S2 16APSK 2/3":  9.0,
-		"DVB-S2 16APSK 3/4":  10.2,
-		"DVB-S2 16APSK 4/5":  11.0,
-		"DVB-S2 16APSK 5/6":  11.6,
-		"DVB-S2 16APSK 8/9":  12.9,
-		"DVB-S2 16APSK 9/10": 13.2,
-		"DVB-S2 32APSK 3/4":  12.8,
-		"DVB-S2 32APSK 4/5":  13.7,
-		"DVB-S2 32APSK 5/6":  14.3,
-		"DVB-S2 32APSK 8/9":  15.7,
-		"DVB-S2 32APSK 9/10": 16.1,
+	// Ideal Es/No in dB for quasi error free decoding without pilots, see EN 302 307 Table 13.
+	// DVB-S2 9/10 is not defined for short frames. See Threshold
+	kModcodThreshold = map[Modcod]float64{
+		{Mode: kDVB_S, Fec: "1/2"}:                                              1.7,
+		{Mode: kDVB_S, Fec: "2/3"}:                                              3.3,
+		{Mode: kDVB_S, Fec: "3/4"}:                                              4.2,
+		{Mode: kDVB_S, Fec: "5/6"}:                                              5.1,
+		{Mode: kDVB_S, Fec: "6/7"}:                                              5.5,
+		{Mode: kDVB_S, Fec: "7/8"}:                                              5.8,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "1/4"}:                      -2.3,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "1/3"}:                      -1.2,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "2/5"}:                      -0.3,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "1/2"}:                      1.0,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "3/5"}:                      2.3,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "2/3"}:                      3.1,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "3/4"}:                      4.1,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "4/5"}:                      4.7,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "5/6"}:                      5.2,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "8/9"}:                      6.2,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "9/10"}:                     6.5,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "3/5"}:                      5.5,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "2/3"}:                      6.6,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "3/4"}:                      7.9,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "5/6"}:                      9.4,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "8/9"}:                      10.7,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "9/10"}:                     11.0,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "2/3"}:                    9.0,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "3/4"}:                    10.2,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "4/5"}:                    11.0,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "5/6"}:                    11.6,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "8/9"}:                    12.9,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "9/10"}:                   13.2,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "3/4"}:                    12.8,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "4/5"}:                    13.7,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "5/6"}:                    14.3,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "8/9"}:                    15.7,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "9/10"}:                   16.1,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "1/4", ShortFrames: true}:   -2.0,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "1/3", ShortFrames: true}:   -1.0,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "2/5", ShortFrames: true}:   -0.1,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "1/2", ShortFrames: true}:   1.3,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "3/5", ShortFrames: true}:   2.6,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "2/3", ShortFrames: true}:   3.4,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "3/4", ShortFrames: true}:   4.4,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "4/5", ShortFrames: true}:   5.0,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "5/6", ShortFrames: true}:   5.6,
+		{Mode: kDVB_S2, Constellation: "QPSK", Fec: "8/9", ShortFrames: true}:   6.6,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "3/5", ShortFrames: true}:   5.8,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "2/3", ShortFrames: true}:   7.0,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "3/4", ShortFrames: true}:   8.3,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "5/6", ShortFrames: true}:   9.8,
+		{Mode: kDVB_S2, Constellation: "8PSK", Fec: "8/9", ShortFrames: true}:   11.1,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "2/3", ShortFrames: true}: 9.4,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "3/4", ShortFrames: true}: 10.6,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "4/5", ShortFrames: true}: 11.4,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "5/6", ShortFrames: true}: 12.0,
+		{Mode: kDVB_S2, Constellation: "16APSK", Fec: "8/9", ShortFrames: true}: 13.3,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "3/4", ShortFrames: true}: 13.2,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "4/5", ShortFrames: true}: 14.1,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "5/6", ShortFrames: true}: 14.7,
+		{Mode: kDVB_S2, Constellation: "32APSK", Fec: "8/9", ShortFrames: true}: 16.1,
 	}
 
 	kAgc1 = [...][2]int{
@@ -225,7 +340,6 @@ var (
 		{182, -71},
 		{200, -72},
 		{225, -73},
-		{225, -73},
 		{255, -74},
 		{290, -75},
 		{325, -76},
@@ -253,28 +367,6 @@ var (
 	}
 )
 
-type esPairStuct struct {
-	waitingFor1stPid  bool
-	waitingFor2ndPid  bool
-	waitingFor1stType bool
-	waitingFor2ndType bool
-	the1stPidValue    string
-	the1stTypeValue   string
-	the2ndPidValue    string
-	the2ndTypeValue   string
-}
-
-func (p *esPairStuct) reset() {
-	p.waitingFor1stPid = true
-	p.waitingFor2ndPid = false
-	p.waitingFor1stType = false
-	p.waitingFor2ndType = false
-	p.the1stPidValue = kDash
-	p.the1stTypeValue = kDash
-	p.the2ndPidValue = kDash
-	p.the2ndTypeValue = kDash
-}
-
 type agcPairStuct struct {
 	waitingForAgc2 bool
 	the1stAgcValue int
@@ -287,6 +379,37 @@ func (p *agcPairStuct) reset() {
 	p.the2ndAgcValue = 0
 }
 
+type lnbStateStruct struct {
+	enabled    bool
+	horizontal bool
+}
+
+// Sets the reported LNB state and warns when it differs from the requested state
+func (p *lnbStateStruct) update() {
+	reported := LnbOff
+	liveData.Lnb = LnbStatusOff
+	if p.enabled && p.horizontal {
+		reported = LnbHorizontal
+		liveData.Lnb = LnbStatus18V
+	} else if p.enabled {
+		reported = LnbVertical
+		liveData.Lnb = LnbStatus13V
+	}
+	requested := requestedLnb()
+	mismatch := reported != requested
+	if mismatch && !liveData.LnbMismatch {
+		qLog.Warn("LNB supply is %v, but %q was requested", liveData.Lnb, requested)
+	}
+	liveData.LnbMismatch = mismatch
+}
+
+// Returns the LNB supply requested when longmynd was started
+func requestedLnb() string {
+	procMu.Lock()
+	defer procMu.Unlock()
+	return tunedOptions.Lnb
+}
+
 func idAndValFromString(s string) (int, string, error) {
 	if !strings.HasPrefix(s, "$") || !strings.Contains(s, ",") || !strings.HasSuffix(s, "\n") || len([]rune(s)) < 3 {
 		return 0, "", errors.New("invalid line")
@@ -316,22 +439,41 @@ const (
 )
 
 var (
-	esPair    = esPairStuct{}
 	agcPair   = new(agcPairStuct)
+	lnbState  = new(lnbStateStruct)
 	liveData  = new(LongmyndData)
 	cacheData = new(LongmyndData)
-	isTuned   bool
-	isPlaying bool
 )
 
+// procMu guards the longmynd and ffplay processes, which are started and stopped
+// from both the UI (via rxControl) and the decoder go routine
+var (
+	procMu      sync.Mutex
+	isTuned     bool
+	isPlaying   bool
+	lockChanged func(locked bool)
+)
+
+// Calls the lockChanged function, if one has been set
+func notifyLockChange(locked bool) {
+	setAgcLocked(locked)
+	procMu.Lock()
+	fn := lockChanged
+	procMu.Unlock()
+	if fn != nil {
+		fn(locked)
+	}
+}
+
 // Reads the longmynd status fifo and translates to formated strings.
 //
 //	The results are sent to a channel of type LongmyndData. When no valid signal is being
 //	received, the LongmyndData fileds will be filled with default values - normally a dash.
-func readLongmynd(fifoPath string, offset float64, lonymyndChannel chan LongmyndData) {
+func readLongmynd(fifoPath string, lonymyndChannel chan LongmyndData) {
 	liveData.reset()
+	clearLatest()
 	cacheData.reset()
-	esPair.reset()
+	resetStreams()
 
 	isLocked := false
 
@@ -355,7 +497,13 @@ func readLongmynd(fifoPath string, offset float64, lonymyndChannel chan Longmynd
 		rawStr, err := reader.ReadString(10) // delimited by char(10) == LF
 		if err != nil {
 			//qLog.Error("reading fifo: %v", err)
+			if isLocked {
+				isLocked = false
+				setCarrier(0, false)
+				notifyLockChange(false)
+			}
 			liveData.reset()
+			clearLatest()
 			cacheData.reset()
 			lonymyndChannel <- *liveData
 			// time.Sleep(100 * time.Millisecond)
@@ -371,11 +519,18 @@ func readLongmynd(fifoPath string, offset float64, lonymyndChannel chan Longmynd
 		switch lmId {
 		case 1: // State
 			id1_setState(lmVal)
+			wasLocked := isLocked
 			isLocked = liveData.State == kLocked
+			setAgcLocked(isLocked) // also after an UnTune, when the lock did not change
+			if isLocked != wasLocked {
+				notifyLockChange(isLocked)
+			}
 			if !isLocked { // if not locked, reset most status
+				setCarrier(0, false)
 				liveData.resetPartial()
+				clearLatest()
 				cacheData.reset()
-				esPair.reset()
+				resetStreams()
 				agcPair.reset()
 				lonymyndChannel <- *liveData
 				// time.Sleep(5 * time.Millisecond)
@@ -386,7 +541,7 @@ func readLongmynd(fifoPath string, offset float64, lonymyndChannel chan Longmynd
 		// case 4: // I Symbol Power - Measure of the current power being seen in the I symbols
 		// case 5: // Q Symbol Power - Measure of the current power being seen in the Q symbols
 		case 6: // Carrier Frequency - During a search this is the carrier frequency being trialled. When locked this is the Carrier Frequency detected in the stream. Sent in KHz
-			id6_setFrequency(lmVal, offset)
+			id6_setFrequency(lmVal, Offset(), isLocked)
 		// case 7: // I Constellation - Single signed byte representing the voltage of a sampled I point
 		// case 8: // Q Constellation - Single signed byte representing the voltage of a sampled Q point
 		case 9: // Symbol Rate - During a search this is the symbol rate being trialled.  When locked this is the symbol rate detected in the stream
@@ -407,28 +562,40 @@ func readLongmynd(fifoPath string, offset float64, lonymyndChannel chan Longmynd
 			id17_setEsType(lmVal)
 		case 18: // MODCOD - Received Modulation & Coding Rate. See MODCOD Lookup Table below
 			id18_setConstellationAndFecAndMargin(lmVal)
-		// case 19: // Short Frames - 1 if received signal is using Short Frames, 0 otherwise (DVB-S2 only)
-		// case 20: // Pilot Symbols - 1 if received signal is using Pilot Symbols, 0 otherwise (DVB-S2 only)
+		case 19: // Short Frames - 1 if received signal is using Short Frames, 0 otherwise (DVB-S2 only)
+			id19_setShortFrames(lmVal)
+		case 20: // Pilot Symbols - 1 if received signal is using Pilot Symbols, 0 otherwise (DVB-S2 only)
+			id20_setPilots(lmVal)
 		// case 21: // LDPC Error Count - LDPC Corrected Errors in last frame (DVB-S2 only)
 		// case 22: // BCH Error Count - BCH Corrected Errors in last frame (DVB-S2 only)
 		// case 23: // BCH Uncorrected - 1 if some BCH-detected errors were not able to be corrected, 0 otherwise (DVB-S2 only)
-		// case 24: // LNB Voltage Enabled - 1 if LNB Voltage Supply is enabled, 0 otherwise (LNB Voltage Supply requires add-on board)
-		// case 25: // LNB H Polarisation - 1 if LNB Voltage Supply is configured for Horizontal Polarisation (18V), 0 otherwise (LNB Voltage Supply requires add-on board)
+		case 24: // LNB Voltage Enabled - 1 if LNB Voltage Supply is enabled, 0 otherwise (LNB Voltage Supply requires add-on board)
+			id24_setLnbEnabled(lmVal)
+		case 25: // LNB H Polarisation - 1 if LNB Voltage Supply is configured for Horizontal Polarisation (18V), 0 otherwise (LNB Voltage Supply requires add-on board)
+			id25_setLnbHorizontal(lmVal)
 		case 26: // AGC1 Gain - Gain value of AGC1 (0: Signal too weak, 65535: Signal too strong)
 			id26_setDbmPower(lmVal)
 		case 27: // AGC2 Gain - Gain value of AGC2 (0: Minimum Gain, 65535: Maximum Gain)
 			id27_setDbmPower(lmVal)
 		} // switch
 
+		procMu.Lock()
+		restartPlayerIfChanged()
 		if isTuned && isLocked && !isPlaying {
-			startFfplay()
+			startPlayer()
 		}
 		if isTuned && !isLocked && isPlaying {
-			stopFfplay()
+			stopPlayer()
 		}
+		lostLock := false
 		if !isTuned && isPlaying {
+			lostLock = isLocked
 			isLocked = false
-			stopFfPlayAndLongmynd()
+			stopPlayerAndLongmynd()
+		}
+		procMu.Unlock()
+		if lostLock {
+			notifyLockChange(false)
 		}
 
 		if isLocked {
@@ -470,13 +637,15 @@ func id1_setState(stateStr string) {
 }
 
 // Carrier Frequency - During a search this is the carrier frequency being trialled. When locked this is the Carrier Frequency detected in the stream. Sent in KHz
-func id6_setFrequency(carrierFrequencyStr string, offset float64) {
+func id6_setFrequency(carrierFrequencyStr string, offset float64, isLocked bool) {
 	kHzFloat, err := strconv.ParseFloat(carrierFrequencyStr, 64)
 	if err != nil {
 		qLog.Warn("Bad carrierFrequencyStr: %v", err)
 		liveData.Frequency = kDash
+		setCarrier(0, false)
 		return
 	}
+	setCarrier(kHzFloat, isLocked)
 	frequency := (kHzFloat + offset) / 1000
 	liveData.Frequency = fmt.Sprintf("%.2f", frequency)
 }
@@ -487,9 +656,11 @@ func id9_setSymbolRate(symbolRateStr string) {
 	if err != nil {
 		qLog.Warn("Bad symbolRateStr: %v", err)
 		liveData.SymbolRate = kDash
+		setSymbolRate(0)
 		return
 	}
 	sysmbolRate := sysmbolRateFloat / 1000.0
+	setSymbolRate(sysmbolRate)
 	liveData.SymbolRate = fmt.Sprintf("%.1f", sysmbolRate)
 }
 
@@ -503,6 +674,8 @@ func id12_setDbMer(merStr string) {
 	}
 	dbMer := dbMerFloat / 10.0
 	liveData.DbMer = fmt.Sprintf("%.1f", dbMer)
+	setLatestMer(dbMer)
+	updateMargin()
 }
 
 // Service Provider - TS Service Provider Name
@@ -533,93 +706,6 @@ func id15_setNullRatio(nullRatioStr string) {
 	liveData.NullRatio = nullRatioStr
 }
 
-// The PID numbers themselves are fairly arbitrary, will vary based on the transmitted signal and don't really mean anything in a single program multiplex.
-func id16_setEsPid(esPidStr string) {
-	// In the status stream 16 and 17 always come in pairs, 16 is the PID and 17 is the type for that PID, e.g.
-	// This means that PID 257 is of type 27 which you look up in the table to be H.264 and PID 258 is type 3 which the table says is MP3.
-	// $16,257 == PID 257 is of type 27 which you look up in the table to be H.264
-	// $17,27  meaning H.264
-	// $16,258 == PID 258 is type 3 which the table says is MP3
-	// $17,3   meaaning MP3
-	// The PID numbers themselves are fairly arbitrary, will vary based on the transmitted signal and don't really mean anything in a single program multiplex.
-
-	if esPair.waitingFor1stPid {
-		esPair.the1stPidValue = esPidStr
-		esPair.waitingFor1stPid = false
-		esPair.waitingFor2ndPid = false
-		esPair.waitingFor1stType = true
-		esPair.waitingFor2ndType = false
-	}
-	if esPair.waitingFor2ndPid {
-		esPair.the2ndPidValue = esPidStr
-		esPair.waitingFor1stPid = false
-		esPair.waitingFor2ndPid = false
-		esPair.waitingFor1stType = false
-		esPair.waitingFor2ndType = true
-	}
-}
-
-// ES TYPE - Elementary Stream Type (repeated as pair with 16 for each ES)
-func id17_setEsType(esType string) {
-	if esPair.waitingFor1stType {
-		esPair.the1stTypeValue = esType
-		esPair.waitingFor1stPid = false
-		esPair.waitingFor2ndPid = true
-		esPair.waitingFor1stType = false
-		esPair.waitingFor2ndType = false
-		liveData.PidPair1 = fmt.Sprintf("%v %v", esPair.the1stPidValue, esPair.the1stTypeValue) // beacon 257 27 = video
-	}
-	if esPair.waitingFor2ndType {
-		esPair.the2ndTypeValue = esType
-		esPair.waitingFor1stPid = true
-		esPair.waitingFor2ndPid = false
-		esPair.waitingFor1stType = false
-		esPair.waitingFor2ndType = false
-		liveData.PidPair2 = fmt.Sprintf("%v %v", esPair.the2ndPidValue, esPair.the2ndTypeValue) // beacon 258 3 = audio
-		// NEWesPair.reset()
-	}
-
-	typ, err := strconv.Atoi(esType)
-	if err != nil {
-		qLog.Warn("Failed to convert esType %v", err)
-		return
-	}
-	switch typ {
-	case 1:
-		liveData.VideoCodec = "MPEG1"
-	case 16:
-		liveData.VideoCodec = "H.263"
-	case 27:
-		liveData.VideoCodec = "H.264"
-	case 33:
-		liveData.VideoCodec = "JPG2K"
-	case 36:
-		liveData.VideoCodec = "H.265"
-	case 51:
-		liveData.VideoCodec = "H.266"
-	default:
-		// liveData.VideoCodec = "???"
-	}
-
-	switch typ {
-	case 2:
-		liveData.AudioCodec = "MPEG2"
-	case 3:
-		liveData.AudioCodec = "MPA" // was "MP3"
-	case 4:
-		liveData.AudioCodec = "MP3"
-	case 15:
-		liveData.AudioCodec = "ACC"
-	case 32:
-		liveData.AudioCodec = "MPA"
-	case 129:
-		liveData.AudioCodec = "AC3"
-	default:
-		// liveData.AudioCodec = "???"
-	}
-
-}
-
 // MODCOD - Received Modulation & Coding Rate. See MODCOD Lookup Table below
 func id18_setConstellationAndFecAndMargin(modcodStr string) {
 	// set Constellation and Fec
@@ -651,43 +737,20 @@ func id18_setConstellationAndFecAndMargin(modcodStr string) {
 		// qLog.Warn("Unknkown longmyndData.mode %v", mode) // TODO: why here, when no signal received ?
 		return
 	}
-	// set Margin
-	if liveData.DbMer == kDash || liveData.Fec == kDash || liveData.Constellation == kDash {
-		qLog.Warn("Failed to set Margin at this time")
-		liveData.DbMargin = kDash
-		return
-	}
-	//key := "KEY"
-	var key string
-	switch liveData.Mode {
-	case kDVB_S:
-		key = kDVB_S + " " + liveData.Fec
-	case kDVB_S2:
-		// TODO: something better than this
-		if liveData.Constellation == "DummyPL" {
-			liveData.DbMargin = "x"
-			return
-		}
-		key = kDVB_S2 + " " + liveData.Constellation + " " + liveData.Fec
-	default:
-		qLog.Warn("Unknown liveData.Mode: %v", liveData.Mode)
-		return
-	}
+	updateMargin()
+}
 
-	// float_threshold := kModeFecThreshold[key]
-	float_threshold, ok := kModeFecThreshold[key]
-	if !ok {
-		qLog.Warn("kModeFecThreshold key not foundr")
-		liveData.DbMargin = kDash
-		return
-	}
-	float_mer, err := strconv.ParseFloat(liveData.DbMer, 64)
-	if err != nil {
-		qLog.Warn("Bad longmyndData.dbMer: %v", err)
-		liveData.DbMargin = kDash
-		return
-	}
-	liveData.DbMargin = fmt.Sprintf("D %.1f", float_mer-float_threshold)
+// LNB Voltage Enabled - 1 if LNB Voltage Supply is enabled, 0 otherwise
+func id24_setLnbEnabled(enabledStr string) {
+	lnbState.enabled = enabledStr == "1"
+}
+
+// LNB H Polarisation - 1 if LNB Voltage Supply is configured for Horizontal Polarisation (18V), 0 otherwise
+//
+//	always follows ID 24 in the status stream
+func id25_setLnbHorizontal(horizontalStr string) {
+	lnbState.horizontal = horizontalStr == "1"
+	lnbState.update()
 }
 
 // AGC1 Gain - Gain value of AGC1 (0: Signal too weak, 65535: Signal too strong)
@@ -717,28 +780,11 @@ func id27_setDbmPower(agc2Str string) {
 	}
 	agcPair.the2ndAgcValue = agc2
 
-	p := 0
-	v := agcPair.the1stAgcValue
-	if v > 0 {
-		for _, n := range kAgc1 {
-			if n[0] >= v {
-				p = n[1]
-				break
-			}
-		}
-	} else {
-		v = agcPair.the2ndAgcValue
-		for _, n := range kAgc2 {
-			if n[0] >= v {
-				p = n[1]
-				break
-			}
-		}
-
-	}
+	p := agcToDbm(agcPair.the1stAgcValue, agcPair.the2ndAgcValue)
 	// qLog.Info("----------------------- agc1 %v agc2 %v", agcPair.the1stAgcValue, agcPair.the2ndAgcValue)
 
-	liveData.DbmPower = fmt.Sprint(p)
+	liveData.DbmPower = fmt.Sprintf("%.0f", p)
+	setLatestPower(p)
 	agcPair.reset()
 }
 
@@ -748,19 +794,19 @@ func id27_setDbmPower(agc2Str string) {
 *
 ************************************************************************/
 
-func stopFfPlayAndLongmynd() {
+func stopPlayerAndLongmynd() {
 	if isPlaying {
-		stopFfplay()
+		stopPlayer()
 	}
 	if isTuned {
 		stopLongmynd()
 	}
 }
 
-// Start Longmynd with frequency and symbolrate
+// Start Longmynd with frequency, symbolrate and options
 //
 //	ie. /home/pi/q100/longmynd/longmynd -S 0.6 requestKHzStr symbolRate
-func startLongmynd(frequency, symbolRate string) {
+func startLongmynd(frequency, symbolRate string, opts LmOptions) {
 	// trim "10491.50 / 00" to "10491.50"
 	frequencySplit := strings.SplitN(frequency, " ", 2)[0]
 	requestedFrequency, err := strconv.ParseFloat(frequencySplit, 64)
@@ -770,14 +816,20 @@ func startLongmynd(frequency, symbolRate string) {
 	}
 	requestKHz := (requestedFrequency * 1000) - lmcfg.Offset
 	requestKHzStr := strconv.FormatFloat(requestKHz, 'f', 0, 64)
-	qLog.Info("longmynd will start...")
-	lmCmd = exec.Command("./longmynd", "-S", "0.6", requestKHzStr, symbolRate)
+	args, err := longmyndArgs(requestKHzStr, symbolRate, opts)
+	if err != nil {
+		qLog.Error("longmynd will not start: %v", err)
+		return
+	}
+	qLog.Info("longmynd will start: %v", strings.Join(args, " "))
+	lmCmd = exec.Command(lmcfg.Binary, args...)
 	lmCmd.Dir = lmcfg.Folder // ie. /home/pi/Q100/longmynd/
 	if err = lmCmd.Start(); err != nil {
 		qLog.Error("failed to start longmynd: %v", err)
 		return
 	}
 	qLog.Info("longmynd has started")
+	tunedOptions = opts
 	isTuned = true
 }
 
@@ -787,7 +839,7 @@ func stopLongmynd() {
 		qLog.Info("longmynd will stop...")
 		lmCmd.Process.Kill()
 		lmCmd.Process.Wait()
-		cmd := exec.Command("/usr/bin/pkill", "longmynd")
+		cmd := exec.Command("/usr/bin/pkill", filepath.Base(lmcfg.Binary))
 		if err := cmd.Start(); err != nil {
 			qLog.Error("failed to stop longmynd: %v", err)
 			return
@@ -797,40 +849,3 @@ func stopLongmynd() {
 	qLog.Info("longmynd has stopped")
 	isTuned = false
 }
-
-// Start ffplay
-//
-//	ie. with position in frame buffer, fullscreen and volume
-func startFfplay() {
-	if !isPlaying && !ffPlayIsACtive {
-		qLog.Info("ffplay will start...")
-		ffPlayCmd = exec.Command("/usr/bin/ffplay", "-left", "800", "-fs", "-volume", fpcfg.Volume, "-i", fpcfg.TsFifo)
-		// ffPlayCmd = exec.Command("/usr/bin/ffplay", "-fs", "-volume", fpcfg.Volume, "-i", fpcfg.TsFifo)
-		if err := ffPlayCmd.Start(); err != nil {
-			qLog.Error("failed to start ffplay: %v", err)
-			return
-		}
-		// cmd.Wait()
-		qLog.Info("ffplay has started")
-	}
-	ffPlayIsACtive = true
-	isPlaying = true
-}
-
-// Stop ffplay
-func stopFfplay() {
-	if isPlaying {
-		qLog.Info("ffplay will stop...")
-		ffPlayCmd.Process.Kill()
-		ffPlayCmd.Process.Wait()
-		cmd := exec.Command("/usr/bin/pkill", "ffplay")
-		if err := cmd.Start(); err != nil {
-			qLog.Error("failed to stop ffplay: %v", err)
-			return
-		}
-		cmd.Wait()
-	}
-	qLog.Info("ffplay has stppoed")
-	ffPlayIsACtive = false
-	isPlaying = false
-}
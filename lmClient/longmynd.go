@@ -0,0 +1,116 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// BEGIN API ********************************************************
+
+// Longmynd command line options
+//
+//	see: https://github.com/BritishAmateurTelevisionClub/longmynd
+type LmOptions struct {
+	InputB      bool     // -w use the BOTTOM (B) input of the NIM instead of TOP (A)
+	Lnb         string   // -p LnbOff, LnbVertical (13V) or LnbHorizontal (18V). Requires the LNB add-on board
+	ScanWidth   float64  // -S halfscan ratio. Zero for kDefaultScanWidth
+	TsIp        string   // -i send the TS to IP_ADDR PORT instead of the TS fifo, see tsInput
+	TsPort      int      //
+	StatusIp    string   // -I not supported, as the status is only read from the status fifo
+	StatusPort  int      //
+	TsTimeout   int      // -r TS timeout in ms. Zero for longmynd's default, -1 to disable
+	Beep        bool     // -b beep to indicate MER
+	SymbolRates []string // additional symbol rates for longmynd to try, after the requested one
+}
+
+const (
+	LnbOff        = ""
+	LnbVertical   = "v"
+	LnbHorizontal = "h"
+)
+
+// LNB supply as reported in LongmyndData
+const (
+	LnbStatusOff = "Off"
+	LnbStatus13V = "13V V"
+	LnbStatus18V = "18V H"
+)
+
+// Returns the options from LmConfig
+func DefaultOptions() LmOptions {
+	return lmcfg.Options
+}
+
+// END API ********************************************************
+
+const kDefaultScanWidth = 0.6
+
+// Returns the longmynd arguments for a frequency in KHz and a symbol rate in KS
+//
+//	ie. -S 0.6 -t TS_FIFO -s STATUS_FIFO 741500 333
+func longmyndArgs(requestKHz string, symbolRate string, opts LmOptions) ([]string, error) {
+	if opts.StatusIp != "" {
+		return nil, errors.New("StatusIp is not supported, the status is read from the status fifo")
+	}
+	var args []string
+	if opts.InputB {
+		args = append(args, "-w")
+	}
+	switch opts.Lnb {
+	case LnbVertical, LnbHorizontal:
+		args = append(args, "-p", opts.Lnb)
+	}
+	scanWidth := opts.ScanWidth
+	if scanWidth == 0 {
+		scanWidth = kDefaultScanWidth
+	}
+	args = append(args, "-S", strconv.FormatFloat(scanWidth, 'f', -1, 64))
+	if opts.TsIp != "" {
+		args = append(args, "-i", opts.TsIp, strconv.Itoa(opts.TsPort))
+	} else {
+		args = append(args, "-t", fpcfg.TsFifo)
+	}
+	args = append(args, "-s", lmcfg.StatusFifo)
+	if opts.TsTimeout != 0 {
+		args = append(args, "-r", strconv.Itoa(opts.TsTimeout))
+	}
+	if opts.Beep {
+		args = append(args, "-b")
+	}
+	symbolRates := []string{symbolRate}
+	for _, sr := range opts.SymbolRates {
+		if sr != symbolRate {
+			symbolRates = append(symbolRates, sr)
+		}
+	}
+	args = append(args, requestKHz, strings.Join(symbolRates, ","))
+	return args, nil
+}
+
+// Returns true if the TS is sent to a multicast group, rather than to an address of this host
+func tsMulticast() bool {
+	ip := net.ParseIP(tunedOptions.TsIp)
+	return ip != nil && ip.IsMulticast()
+}
+
+// Returns the transport stream input for the video player
+//
+//	the TS is received on all interfaces, ie. udp://@:PORT, so TsIp may be any address
+//	of this host. A multicast group is joined, ie. udp://@GROUP:PORT
+func tsInput() string {
+	if tunedOptions.TsIp != "" {
+		if tsMulticast() {
+			return fmt.Sprintf("udp://@%v:%v", tunedOptions.TsIp, tunedOptions.TsPort)
+		}
+		return fmt.Sprintf("udp://@:%v", tunedOptions.TsPort)
+	}
+	return fpcfg.TsFifo
+}
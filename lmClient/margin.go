@@ -0,0 +1,145 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// BEGIN API ********************************************************
+
+type (
+	// Everything the decode threshold depends on
+	Modcod struct {
+		Mode          string // "DVB-S" or "DVB-S2"
+		Constellation string
+		Fec           string
+		ShortFrames   bool // DVB-S2 only
+		Pilots        bool // DVB-S2 only
+	}
+	MarginState int
+)
+
+const (
+	MarginUnknown MarginState = iota
+	MarginFailing
+	MarginMarginal
+	MarginSolid
+)
+
+func (s MarginState) String() string {
+	switch s {
+	case MarginFailing:
+		return "Failing"
+	case MarginMarginal:
+		return "Marginal"
+	case MarginSolid:
+		return "Solid"
+	}
+	return "Unknown"
+}
+
+// Returns the MER in dB needed to decode m, and false if m has no threshold
+//
+//	the thresholds are looked up by modcod and frame length, plus the energy spent on pilots
+func Threshold(m Modcod) (float64, bool) {
+	switch m.Mode {
+	case kDVB_S:
+		threshold, ok := kModcodThreshold[Modcod{Mode: kDVB_S, Fec: m.Fec}]
+		return threshold, ok
+	case kDVB_S2:
+		key := m
+		key.Pilots = false
+		threshold, ok := kModcodThreshold[key]
+		if !ok {
+			return 0, false
+		}
+		if m.Pilots {
+			threshold += pilotLossDb(m)
+		}
+		return threshold, true
+	}
+	return 0, false
+}
+
+// Returns the state of a margin in dB
+func MarginStateOf(margin float64) MarginState {
+	switch {
+	case math.IsNaN(margin):
+		return MarginUnknown
+	case margin < 0:
+		return MarginFailing
+	case margin < kSolidMarginDb:
+		return MarginMarginal
+	}
+	return MarginSolid
+}
+
+// END API ********************************************************
+
+const kSolidMarginDb = 2.0 // enough to ride out rain fade and dish wobble
+
+var kBitsPerSymbol = map[string]int{"QPSK": 2, "8PSK": 3, "16APSK": 4, "32APSK": 5}
+
+// Returns the loss in dB of a DVB-S2 frame with pilots
+//
+//	ie. a block of 36 pilot symbols after every 16 slots of 90 symbols, except the last
+func pilotLossDb(m Modcod) float64 {
+	bits := 64800
+	if m.ShortFrames {
+		bits = 16200
+	}
+	slots := bits / kBitsPerSymbol[m.Constellation] / 90
+	symbols := float64(90 * (slots + 1)) // plus the PL header
+	pilots := float64(36 * ((slots - 1) / 16))
+	return 10 * math.Log10((symbols+pilots)/symbols)
+}
+
+// Short Frames - 1 if received signal is using Short Frames, 0 otherwise (DVB-S2 only)
+func id19_setShortFrames(shortStr string) {
+	liveData.ShortFrames = shortStr == "1"
+	updateMargin()
+}
+
+// Pilot Symbols - 1 if received signal is using Pilot Symbols, 0 otherwise (DVB-S2 only)
+func id20_setPilots(pilotsStr string) {
+	liveData.Pilots = pilotsStr == "1"
+	updateMargin()
+}
+
+// Sets the margin from the MER and the modcod. Called whenever either changes
+func updateMargin() {
+	liveData.DbMargin = kDash
+	liveData.Margin = 0
+	liveData.MarginState = MarginUnknown
+	if liveData.DbMer == kDash || liveData.Fec == kDash || liveData.Constellation == kDash {
+		setLatestMargin(math.NaN())
+		return
+	}
+	threshold, ok := Threshold(Modcod{
+		Mode:          liveData.Mode,
+		Constellation: liveData.Constellation,
+		Fec:           liveData.Fec,
+		ShortFrames:   liveData.ShortFrames,
+		Pilots:        liveData.Pilots,
+	})
+	if !ok { // eg. DummyPL frames
+		setLatestMargin(math.NaN())
+		return
+	}
+	mer, err := strconv.ParseFloat(liveData.DbMer, 64)
+	if err != nil {
+		setLatestMargin(math.NaN())
+		return
+	}
+	margin := mer - threshold
+	liveData.DbMargin = fmt.Sprintf("D %.1f", margin)
+	liveData.Margin = margin
+	liveData.MarginState = MarginStateOf(margin)
+	setLatestMargin(margin)
+}
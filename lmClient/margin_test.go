@@ -0,0 +1,80 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"math"
+	"testing"
+)
+
+func TestThreshold(t *testing.T) {
+	tests := []struct {
+		m      Modcod
+		want   float64
+		wantOk bool
+	}{
+		{Modcod{Mode: kDVB_S, Constellation: "QPSK", Fec: "1/2"}, 1.7, true},
+		{Modcod{Mode: kDVB_S, Constellation: "QPSK", Fec: "7/8"}, 5.8, true},
+		{Modcod{Mode: kDVB_S, Fec: "9/10"}, 0, false},
+		{Modcod{Mode: kDVB_S2, Constellation: "QPSK", Fec: "1/4"}, -2.3, true},
+		{Modcod{Mode: kDVB_S2, Constellation: "QPSK", Fec: "1/4", ShortFrames: true}, -2.0, true},
+		{Modcod{Mode: kDVB_S2, Constellation: "8PSK", Fec: "2/3"}, 6.6, true},
+		{Modcod{Mode: kDVB_S2, Constellation: "8PSK", Fec: "2/3", ShortFrames: true}, 7.0, true},
+		{Modcod{Mode: kDVB_S2, Constellation: "32APSK", Fec: "9/10"}, 16.1, true},
+		{Modcod{Mode: kDVB_S2, Constellation: "QPSK", Fec: "9/10", ShortFrames: true}, 0, false},
+		{Modcod{Mode: kDVB_S2, Constellation: "8PSK", Fec: "1/2"}, 0, false},
+		{Modcod{Mode: kDVB_S2, Constellation: "DummyPL", Fec: "x"}, 0, false},
+		{Modcod{Mode: "-", Fec: "1/2"}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := Threshold(tt.m)
+		if ok != tt.wantOk || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Threshold(%+v) = %v, %v, want %v, %v", tt.m, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestPilotLoss(t *testing.T) {
+	tests := []struct {
+		m    Modcod
+		want float64 // dB
+	}{
+		{Modcod{Constellation: "QPSK"}, 0.105},                      // 22 pilot blocks in 361 slots
+		{Modcod{Constellation: "QPSK", ShortFrames: true}, 0.094},   // 5 in 91
+		{Modcod{Constellation: "32APSK"}, 0.095},                    // 8 in 145
+		{Modcod{Constellation: "32APSK", ShortFrames: true}, 0.093}, // 2 in 37
+	}
+	for _, tt := range tests {
+		if got := pilotLossDb(tt.m); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("pilotLossDb(%+v) = %.4f, want %.3f", tt.m, got, tt.want)
+		}
+		m := tt.m
+		m.Mode, m.Fec = kDVB_S2, "3/4"
+		without, _ := Threshold(m)
+		m.Pilots = true
+		if with, _ := Threshold(m); math.Abs(with-without-pilotLossDb(tt.m)) > 1e-9 {
+			t.Errorf("Threshold(%+v) = %v, want %v plus the pilot loss", m, with, without)
+		}
+	}
+}
+
+func TestMarginStateOf(t *testing.T) {
+	tests := []struct {
+		margin float64
+		want   MarginState
+	}{
+		{math.NaN(), MarginUnknown},
+		{-0.1, MarginFailing},
+		{0, MarginMarginal},
+		{kSolidMarginDb - 0.1, MarginMarginal},
+		{kSolidMarginDb, MarginSolid},
+	}
+	for _, tt := range tests {
+		if got := MarginStateOf(tt.margin); got != tt.want {
+			t.Errorf("MarginStateOf(%v) = %v, want %v", tt.margin, got, tt.want)
+		}
+	}
+}
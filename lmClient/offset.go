@@ -0,0 +1,97 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ********************************************************
+
+// Returns the LNB offset in KHz
+func Offset() float64 {
+	procMu.Lock()
+	defer procMu.Unlock()
+	return lmcfg.Offset
+}
+
+// Sets the LNB offset in KHz and saves it to LmConfig.OffsetFile
+//
+//	applies to the next Tune and to all frequencies decoded from now on
+func SetOffset(offset float64) error {
+	procMu.Lock()
+	lmcfg.Offset = offset
+	path := lmcfg.OffsetFile
+	procMu.Unlock()
+	qLog.Info("LNB offset set to %.0f KHz", offset)
+	if path == "" {
+		return nil
+	}
+	return os.WriteFile(path, []byte(strconv.FormatFloat(offset, 'f', 0, 64)+"\n"), 0644)
+}
+
+// Returns the carrier frequency in KHz, before adding the LNB offset, and true if locked
+func CarrierKHz() (float64, bool) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	return lastCarrierKHz, lastCarrierLocked
+}
+
+// Returns the symbol rate in KS reported by longmynd, and true if locked
+func SymbolRateKS() (float64, bool) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	return lastSymbolRateKS, lastCarrierLocked
+}
+
+// END API ********************************************************
+
+// guarded by procMu
+var (
+	lastCarrierKHz    float64
+	lastCarrierLocked bool
+	lastSymbolRateKS  float64
+)
+
+// Replaces lmcfg.Offset with the one saved by a previous calibration, if any
+func loadOffset() {
+	if lmcfg.OffsetFile == "" {
+		return
+	}
+	data, err := os.ReadFile(lmcfg.OffsetFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			qLog.Warn("Failed to read LNB offset: %v", err)
+		}
+		return
+	}
+	offset, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		qLog.Warn("Bad LNB offset in %v: %v", lmcfg.OffsetFile, err)
+		return
+	}
+	qLog.Info("LNB offset %.0f KHz loaded from %v", offset, lmcfg.OffsetFile)
+	lmcfg.Offset = offset
+}
+
+// Saves the symbol rate for SymbolRateKS
+func setSymbolRate(kS float64) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	lastSymbolRateKS = kS
+}
+
+// Saves the carrier frequency for CarrierKHz
+func setCarrier(kHz float64, locked bool) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	lastCarrierKHz = kHz
+	lastCarrierLocked = locked
+}
@@ -0,0 +1,271 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ********************************************************
+
+// Command line arguments for a video player
+//
+//	the following placeholders are replaced when the player is started:
+//	{volume} 0 to 100, {gain} volume as 0.0 to 1.0, {left} {top} {width} {height} in pixels,
+//	{audio} the audio device and {input} the transport stream
+type PlayerTemplate struct {
+	Binary      string   // used when FpConfig.Binary is empty
+	Common      []string // always passed first
+	Volume      []string
+	Window      []string // when FpConfig.Width and Height are not zero, or when embedded
+	Fullscreen  []string // otherwise
+	AudioDevice []string // only when FpConfig.AudioDevice is not empty
+	AudioEnv    []string // environment variables, only when FpConfig.AudioDevice is not empty
+	Input       []string // always passed last
+	Version     []string // print the version, not used to play
+}
+
+const (
+	PlayerFfplay = "ffplay"
+	PlayerMpv    = "mpv"
+	PlayerVlc    = "vlc"
+)
+
+// Sets the region of the Gio window to be used for embedded video, or a zero width or height to hide it
+//
+//	called from the UI layout, so it only records the region. A playing embedded video is
+//	stopped, and restarted in the new region, by the decoder
+func SetVideoRegion(left, top, width, height int) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	embedRegion = videoRegion{left, top, width, height}
+}
+
+// Returns the player volume from 0 to 100
+func Volume() int {
+	procMu.Lock()
+	defer procMu.Unlock()
+	v, _ := strconv.Atoi(fpcfg.Volume)
+	return v
+}
+
+// Sets the player volume from 0 to 100
+//
+//	called from the settings. A playing video will be restarted with the new volume by the decoder
+func SetVolume(volume int) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	v := strconv.Itoa(volume)
+	if v == fpcfg.Volume {
+		return
+	}
+	fpcfg.Volume = v
+	qLog.Info("Volume set to %v", v)
+	restartPending = isPlaying && recordPath == ""
+}
+
+// Returns true while the video player is running
+func IsPlaying() bool {
+	procMu.Lock()
+	defer procMu.Unlock()
+	return isPlaying
+}
+
+// END API ********************************************************
+
+var kPlayerTemplates = map[string]PlayerTemplate{
+	PlayerFfplay: {
+		Binary:      "/usr/bin/ffplay",
+		Common:      []string{"-hide_banner", "-loglevel", "error"},
+		Volume:      []string{"-volume", "{volume}"},
+		Window:      []string{"-left", "{left}", "-top", "{top}", "-x", "{width}", "-y", "{height}", "-noborder"},
+		Fullscreen:  []string{"-left", "{left}", "-top", "{top}", "-fs"},
+		AudioDevice: []string{},
+		AudioEnv:    []string{"SDL_AUDIODRIVER=alsa", "AUDIODEV={audio}"},
+		Input:       []string{"-i", "{input}"},
+		Version:     []string{"-version"},
+	},
+	PlayerMpv: {
+		Binary:      "/usr/bin/mpv",
+		Common:      []string{"--no-terminal", "--profile=low-latency"},
+		Volume:      []string{"--volume={volume}"},
+		Window:      []string{"--geometry={width}x{height}+{left}+{top}", "--no-border"},
+		Fullscreen:  []string{"--geometry=+{left}+{top}", "--fs"},
+		AudioDevice: []string{"--audio-device={audio}"},
+		Input:       []string{"{input}"},
+		Version:     []string{"--version"},
+	},
+	PlayerVlc: {
+		Binary:      "/usr/bin/cvlc",
+		Common:      []string{"--quiet", "--no-video-title-show"},
+		Volume:      []string{"--gain={gain}"},
+		Window:      []string{"--video-x={left}", "--video-y={top}", "--width={width}", "--height={height}", "--no-video-deco"},
+		Fullscreen:  []string{"--video-x={left}", "--video-y={top}", "--fullscreen"},
+		AudioDevice: []string{"--aout=alsa", "--alsa-audio-device={audio}"},
+		Input:       []string{"{input}"},
+		Version:     []string{"--version"},
+	},
+}
+
+type videoRegion struct {
+	left, top, width, height int
+}
+
+// guarded by procMu
+var (
+	playerCmd      *exec.Cmd
+	playerIsActive bool // TODO: temp fix to prevent more than one player instance
+	embedRegion    videoRegion
+	playerRegion   videoRegion // of the running embedded player
+	restartPending bool        // the player settings have changed
+)
+
+// Stops the player if its region or settings have changed, to be started again by the decoder.
+// Must be called with procMu held
+func restartPlayerIfChanged() {
+	if !isPlaying || recordPath != "" {
+		restartPending = false
+		return
+	}
+	if restartPending || (fpcfg.Embed && playerRegion != embedRegion) {
+		restartPending = false
+		stopPlayer()
+	}
+}
+
+// Returns the template for the configured player
+func playerTemplate() PlayerTemplate {
+	if fpcfg.Template != nil {
+		return *fpcfg.Template
+	}
+	name := fpcfg.Player
+	if name == "" {
+		name = PlayerFfplay
+	}
+	tmpl, ok := kPlayerTemplates[name]
+	if !ok {
+		qLog.Warn("Unknown player %q, will use %v", name, PlayerFfplay)
+		tmpl = kPlayerTemplates[PlayerFfplay]
+	}
+	return tmpl
+}
+
+// Returns the player binary, arguments and environment from the template and configuration
+func playerCommandLine(tmpl PlayerTemplate) (string, []string, []string) {
+	binary := fpcfg.Binary
+	if binary == "" {
+		binary = tmpl.Binary
+	}
+
+	region := videoRegion{fpcfg.Left, fpcfg.Top, fpcfg.Width, fpcfg.Height}
+	if fpcfg.Embed {
+		region = embedRegion
+	}
+
+	gain := 1.0
+	if v, err := strconv.ParseFloat(fpcfg.Volume, 64); err == nil {
+		gain = v / 100
+	}
+
+	r := strings.NewReplacer(
+		"{volume}", fpcfg.Volume,
+		"{gain}", strconv.FormatFloat(gain, 'f', 2, 64),
+		"{left}", strconv.Itoa(region.left),
+		"{top}", strconv.Itoa(region.top),
+		"{width}", strconv.Itoa(region.width),
+		"{height}", strconv.Itoa(region.height),
+		"{audio}", fpcfg.AudioDevice,
+		"{input}", tsInput(),
+	)
+	expand := func(args []string, to []string) []string {
+		for _, a := range args {
+			to = append(to, r.Replace(a))
+		}
+		return to
+	}
+
+	var args, env []string
+	args = expand(tmpl.Common, args)
+	args = expand(tmpl.Volume, args)
+	if region.width > 0 && region.height > 0 {
+		args = expand(tmpl.Window, args)
+	} else {
+		args = expand(tmpl.Fullscreen, args)
+	}
+	if fpcfg.AudioDevice != "" {
+		args = expand(tmpl.AudioDevice, args)
+		env = expand(tmpl.AudioEnv, env)
+	}
+	args = expand(tmpl.Input, args)
+	return binary, args, env
+}
+
+// Start the video player, or the recorder while recording
+//
+//	ie. with position in frame buffer, fullscreen and volume. Must be called with procMu held
+func startPlayer() {
+	if recordPath != "" {
+		if isPlaying || time.Now().Before(recorderRetry) {
+			return
+		}
+		if err := startRecorder(); err != nil {
+			qLog.Error("%v, will retry in %v", err, kRecorderRetry)
+			recorderRetry = time.Now().Add(kRecorderRetry)
+			return
+		}
+		isPlaying = true
+		return
+	}
+	if !isPlaying && !playerIsActive {
+		if fpcfg.Embed && (embedRegion.width == 0 || embedRegion.height == 0) {
+			return // until the spectrum, which it covers, is shown
+		}
+		playerRegion = embedRegion
+		binary, args, env := playerCommandLine(playerTemplate())
+		qLog.Info("player will start: %v %v", binary, strings.Join(args, " "))
+		playerCmd = exec.Command(binary, args...)
+		if len(env) > 0 {
+			playerCmd.Env = append(os.Environ(), env...)
+		}
+		if err := playerCmd.Start(); err != nil {
+			qLog.Error("failed to start player: %v", err)
+			return
+		}
+		qLog.Info("player has started")
+	}
+	playerIsActive = true
+	isPlaying = true
+}
+
+// Stop the video player, or the recorder while recording. Must be called with procMu held
+func stopPlayer() {
+	if recordPath != "" {
+		stopRecorder()
+		isPlaying = false
+		return
+	}
+	if isPlaying {
+		qLog.Info("player will stop...")
+		playerCmd.Process.Kill()
+		playerCmd.Process.Wait()
+		cmd := exec.Command("/usr/bin/pkill", filepath.Base(playerCmd.Path))
+		if err := cmd.Start(); err != nil {
+			qLog.Error("failed to stop player: %v", err)
+			return
+		}
+		cmd.Wait()
+	}
+	qLog.Info("player has stopped")
+	playerIsActive = false
+	isPlaying = false
+}
@@ -0,0 +1,111 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ********************************************************
+
+// Records the transport stream to a file, instead of playing it, while locked
+//
+//	the file is appended to, so recording survives losing lock
+func StartRecording(path string) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	if recordPath == path {
+		return
+	}
+	if isPlaying {
+		stopPlayer() // the decoder restarts it as a recorder
+	}
+	recordPath = path
+	recorderRetry = time.Time{}
+	qLog.Info("Recording to %v", path)
+}
+
+// Stops recording, and plays the transport stream again
+func StopRecording() {
+	procMu.Lock()
+	defer procMu.Unlock()
+	if recordPath == "" {
+		return
+	}
+	if isPlaying {
+		stopPlayer()
+	}
+	qLog.Info("Recording to %v has stopped", recordPath)
+	recordPath = ""
+}
+
+// Returns the file being recorded to, or an empty string
+func RecordingPath() string {
+	procMu.Lock()
+	defer procMu.Unlock()
+	return recordPath
+}
+
+// END API ********************************************************
+
+// between attempts to start the recorder after a failure
+const kRecorderRetry = 5 * time.Second
+
+// guarded by procMu
+var (
+	recordPath    string
+	recorderTs    io.Closer // closed to stop the recorder
+	recorderRetry time.Time // not before, after a failure
+)
+
+// Starts copying the transport stream to recordPath. Must be called with procMu held
+func startRecorder() error {
+	file, err := os.OpenFile(recordPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %v", err)
+	}
+	ts, err := openTs()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open the transport stream: %v", err)
+	}
+	recorderTs = ts
+	go func() {
+		defer file.Close()
+		n, err := io.Copy(file, ts)
+		qLog.Info("recorder has stopped after %v bytes: %v", n, err)
+	}()
+	qLog.Info("recorder has started")
+	return nil
+}
+
+// Stops the recorder. Must be called with procMu held
+func stopRecorder() {
+	if recorderTs != nil {
+		recorderTs.Close()
+		recorderTs = nil
+	}
+}
+
+// Opens the transport stream from longmynd, as used by the player
+//
+//	the fifo is opened for writing too, so opening never blocks and reading
+//	does not end if longmynd closes it
+func openTs() (io.ReadCloser, error) {
+	if tunedOptions.TsIp != "" {
+		if tsMulticast() {
+			return net.ListenMulticastUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP(tunedOptions.TsIp), Port: tunedOptions.TsPort})
+		}
+		return net.ListenUDP("udp", &net.UDPAddr{Port: tunedOptions.TsPort})
+	}
+	return os.OpenFile(fpcfg.TsFifo, os.O_RDWR, os.ModeNamedPipe)
+}
@@ -0,0 +1,187 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package lmClient
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ********************************************************
+
+// Stream kinds
+const (
+	StreamVideo   = "Video"
+	StreamAudio   = "Audio"
+	StreamData    = "Data"
+	StreamUnknown = "Unknown"
+)
+
+// An elementary stream of the service being received
+type ElementaryStream struct {
+	Pid   int
+	Type  int    // MPEG-TS stream type
+	Kind  string // StreamVideo, StreamAudio, StreamData or StreamUnknown
+	Codec string
+}
+
+// Returns a copy of the elementary streams, in order of PID
+func ElementaryStreams() []ElementaryStream {
+	esMu.Lock()
+	defer esMu.Unlock()
+	streams := make([]ElementaryStream, 0, len(esList))
+	for _, es := range esList {
+		streams = append(streams, es.ElementaryStream)
+	}
+	return streams
+}
+
+// END API ********************************************************
+
+// streams not repeated by longmynd for this long have gone
+const kStreamTimeout = 10 * time.Second
+
+type esEntry struct {
+	ElementaryStream
+	lastSeen time.Time
+}
+
+// esMu guards esList
+var (
+	esMu   sync.Mutex
+	esList []esEntry
+)
+
+// only used by the decoder go routine
+var pendingPid = -1 // from ID 16, waiting for its type from ID 17
+
+var kStreamTypes = map[int]struct{ kind, codec string }{
+	1:   {StreamVideo, "MPEG1"},
+	2:   {StreamVideo, "MPEG2"},
+	3:   {StreamAudio, "MPA"},
+	4:   {StreamAudio, "MP3"},
+	5:   {StreamData, "Private"},
+	6:   {StreamData, "PES"}, // usually subtitles or teletext
+	11:  {StreamData, "DSM-CC"},
+	13:  {StreamData, "DSM-CC"},
+	15:  {StreamAudio, "AAC"},
+	16:  {StreamVideo, "H.263"},
+	17:  {StreamAudio, "AAC"}, // LATM
+	21:  {StreamData, "Metadata"},
+	27:  {StreamVideo, "H.264"},
+	32:  {StreamAudio, "MPA"},
+	33:  {StreamVideo, "JPG2K"},
+	36:  {StreamVideo, "H.265"},
+	51:  {StreamVideo, "H.266"},
+	129: {StreamAudio, "AC3"},
+	134: {StreamData, "SCTE-35"},
+	135: {StreamAudio, "EAC3"},
+}
+
+// Returns a stream with its kind and codec looked up from the stream type
+func newElementaryStream(pid, typ int) ElementaryStream {
+	es := ElementaryStream{Pid: pid, Type: typ, Kind: StreamUnknown, Codec: fmt.Sprintf("type %v", typ)}
+	if t, ok := kStreamTypes[typ]; ok {
+		es.Kind = t.kind
+		es.Codec = t.codec
+	}
+	return es
+}
+
+// Forgets all the streams. Called whenever lock is lost
+func resetStreams() {
+	pendingPid = -1
+	esMu.Lock()
+	defer esMu.Unlock()
+	esList = nil
+}
+
+// The PID numbers themselves are fairly arbitrary, will vary based on the transmitted signal and don't really mean anything in a single program multiplex.
+func id16_setEsPid(esPidStr string) {
+	// In the status stream 16 and 17 always come in pairs, 16 is the PID and 17 is the type for that PID, e.g.
+	// $16,257 == PID 257 is of type 27 which you look up in the table to be H.264
+	// $17,27  meaning H.264
+	// $16,258 == PID 258 is type 3 which the table says is MPA
+	// $17,3   meaning MPA
+	// and so on for every elementary stream of the service
+	pid, err := strconv.Atoi(esPidStr)
+	if err != nil {
+		qLog.Warn("Failed to convert esPidStr %v", err)
+		pendingPid = -1
+		return
+	}
+	pendingPid = pid
+}
+
+// ES TYPE - Elementary Stream Type (repeated as pair with 16 for each ES)
+func id17_setEsType(esType string) {
+	if pendingPid < 0 {
+		return
+	}
+	pid := pendingPid
+	pendingPid = -1
+	typ, err := strconv.Atoi(esType)
+	if err != nil {
+		qLog.Warn("Failed to convert esType %v", err)
+		return
+	}
+	updateStreams(newElementaryStream(pid, typ), time.Now())
+}
+
+// Adds or replaces a stream, forgets those that have timed out and updates liveData
+func updateStreams(es ElementaryStream, now time.Time) {
+	esMu.Lock()
+	i, found := slices.BinarySearchFunc(esList, es.Pid, func(e esEntry, pid int) int {
+		return e.Pid - pid
+	})
+	if found {
+		esList[i] = esEntry{es, now}
+	} else {
+		esList = slices.Insert(esList, i, esEntry{es, now})
+	}
+	esList = slices.DeleteFunc(esList, func(e esEntry) bool {
+		return now.Sub(e.lastSeen) > kStreamTimeout
+	})
+	streams := make([]ElementaryStream, 0, len(esList))
+	for _, e := range esList {
+		streams = append(streams, e.ElementaryStream)
+	}
+	esMu.Unlock()
+
+	liveData.EsCount = len(streams)
+	liveData.VideoCodec = codecSummary(streams, StreamVideo)
+	liveData.AudioCodec = codecSummary(streams, StreamAudio)
+	liveData.PidPair1 = kDash
+	liveData.PidPair2 = kDash
+	if len(streams) > 0 {
+		liveData.PidPair1 = fmt.Sprintf("%v %v", streams[0].Pid, streams[0].Type) // beacon 257 27 = video
+	}
+	if len(streams) > 1 {
+		liveData.PidPair2 = fmt.Sprintf("%v %v", streams[1].Pid, streams[1].Type) // beacon 258 3 = audio
+	}
+}
+
+// Returns the codec of the first stream of kind, eg. "H.264", and "+n" for any others
+func codecSummary(streams []ElementaryStream, kind string) string {
+	var codecs []string
+	for _, es := range streams {
+		if es.Kind == kind {
+			codecs = append(codecs, es.Codec)
+		}
+	}
+	switch len(codecs) {
+	case 0:
+		return kDash
+	case 1:
+		return codecs[0]
+	}
+	return fmt.Sprintf("%v+%v", codecs[0], len(codecs)-1)
+}
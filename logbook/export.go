@@ -0,0 +1,153 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package logbook
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"q100receiver-bookworm/lmClient"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+// Writes the whole logbook as CSV and returns the path of the file
+func ExportCsv() (string, error) {
+	entries, path, err := prepareExport("csv")
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"Start UTC", "Duration", "Channel", "Frequency", "Symbol Rate", "Mode",
+		"Constellation", "FEC", "Peak MER", "Video", "Audio", "Provider", "Service"})
+	for _, e := range entries {
+		w.Write([]string{
+			e.Start.UTC().Format(time.DateTime),
+			e.Duration.String(),
+			strconv.Itoa(e.Channel),
+			e.Frequency,
+			e.SymbolRate,
+			e.Mode,
+			e.Constellation,
+			e.Fec,
+			fmt.Sprintf("%.1f", e.PeakMer),
+			e.VideoCodec,
+			e.AudioCodec,
+			e.Provider,
+			e.Service,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	qLog.Info("Logbook exported to %v", path)
+	return path, nil
+}
+
+// Writes the whole logbook as ADIF, as heard by a short wave listener, and returns the path of the file
+//
+//	and the number of entries skipped, as they have no provider or service to use as the callsign
+func ExportAdif() (string, int, error) {
+	entries, path, err := prepareExport("adi")
+	if err != nil {
+		return "", 0, err
+	}
+	var skipped int
+	mu.Lock()
+	station := lbCfg.StationCallsign
+	mu.Unlock()
+	var sb strings.Builder
+	sb.WriteString("Q-100 Receiver logbook\n")
+	adifField(&sb, "ADIF_VER", "3.1.4")
+	adifField(&sb, "PROGRAMID", "Q-100 Receiver")
+	sb.WriteString("<EOH>\n")
+	for _, e := range entries {
+		call := adifCall(e)
+		if call == "" {
+			skipped++
+			continue
+		}
+		start := e.Start.UTC()
+		end := start.Add(e.Duration)
+		adifField(&sb, "CALL", call)
+		adifField(&sb, "QSO_DATE", start.Format("20060102"))
+		adifField(&sb, "TIME_ON", start.Format("150405"))
+		adifField(&sb, "QSO_DATE_OFF", end.Format("20060102"))
+		adifField(&sb, "TIME_OFF", end.Format("150405"))
+		adifField(&sb, "BAND", "3cm")
+		adifField(&sb, "FREQ", e.Frequency)
+		adifField(&sb, "MODE", "ATV")
+		adifField(&sb, "PROP_MODE", "SAT")
+		adifField(&sb, "SAT_NAME", "QO-100")
+		adifField(&sb, "SWL", "Y")
+		adifField(&sb, "STATION_CALLSIGN", station)
+		adifField(&sb, "COMMENT", fmt.Sprintf("%v %v %v %v SR %v MER %.1f %v %v",
+			e.Service, e.Mode, e.Constellation, e.Fec, e.SymbolRate, e.PeakMer, e.VideoCodec, e.AudioCodec))
+		sb.WriteString("<EOR>\n")
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return "", 0, err
+	}
+	if skipped > 0 {
+		qLog.Warn("Logbook: %v entries without a callsign were not exported", skipped)
+	}
+	qLog.Info("Logbook exported to %v", path)
+	return path, skipped, nil
+}
+
+// END API ****************************************************
+
+// Returns every entry and the path to export them to, with extension ext
+func prepareExport(ext string) ([]Entry, string, error) {
+	mu.Lock()
+	file := lbCfg.File
+	folder := lbCfg.ExportFolder
+	mu.Unlock()
+	if file == "" {
+		return nil, "", errors.New("no logbook file")
+	}
+	entries, err := readAll(file)
+	if err != nil {
+		return nil, "", err
+	}
+	if folder == "" {
+		folder = filepath.Dir(file)
+	}
+	name := "logbook-" + time.Now().UTC().Format("20060102-150405") + "." + ext
+	return entries, filepath.Join(folder, name), nil
+}
+
+// Returns the callsign of an entry, ie. the provider or else the service, or an empty string if neither was decoded
+func adifCall(e Entry) string {
+	for _, call := range []string{e.Provider, e.Service} {
+		if call = strings.TrimSpace(call); call != "" && call != lmClient.NoValue {
+			return call
+		}
+	}
+	return ""
+}
+
+// Writes an ADIF field, if it has a value
+func adifField(sb *strings.Builder, name, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(sb, "<%v:%v>%v ", name, len(value), value)
+}
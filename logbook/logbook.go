@@ -0,0 +1,212 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package logbook
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"q100receiver-bookworm/lmClient"
+	"q100receiver-bookworm/spectrumClient"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+type (
+	LbConfig struct {
+		File            string        // JSON lines, one entry per line, appended
+		ExportFolder    string        // for CSV and ADIF exports, empty for the folder of File
+		StationCallsign string        // this station, for ADIF exports
+		MinLock         time.Duration // shorter locks are not logged, zero for the default
+	}
+	// A station heard, from when longmynd locked until it lost lock
+	Entry struct {
+		Start         time.Time     `json:"start"`
+		Duration      time.Duration `json:"duration"`
+		Channel       int           `json:"channel"` // 0 for the beacon, -1 if not known
+		Frequency     string        `json:"frequency"`
+		SymbolRate    string        `json:"symbol_rate"`
+		Mode          string        `json:"mode"`
+		Constellation string        `json:"constellation"`
+		Fec           string        `json:"fec"`
+		PeakMer       float64       `json:"peak_mer"`
+		VideoCodec    string        `json:"video_codec"`
+		AudioCodec    string        `json:"audio_codec"`
+		Provider      string        `json:"provider"` // usually the callsign
+		Service       string        `json:"service"`
+	}
+)
+
+func Intitialize(cfg LbConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	lbCfg = cfg
+	if lbCfg.MinLock <= 0 {
+		lbCfg.MinLock = kDefaultMinLock
+	}
+	recent = loadRecent(lbCfg.File)
+	qLog.Info("Logbook has %v recent entries", len(recent))
+}
+
+// Records a station from the longmynd data. Called whenever the data changes
+func Update(data lmClient.LongmyndData) {
+	mu.Lock()
+	defer mu.Unlock()
+	known := data.IsLocked() &&
+		(data.Provider != lmClient.NoValue || data.Service != lmClient.NoValue)
+	if current != nil && (!known || data.Provider != current.Provider || data.Service != current.Service) {
+		finish(time.Now())
+	}
+	if !known {
+		return
+	}
+	if current == nil {
+		current = &Entry{
+			Start:    time.Now(),
+			Channel:  -1,
+			Provider: data.Provider,
+			Service:  data.Service,
+		}
+	}
+	current.update(data)
+}
+
+// Logs the station being received, if any. Called before shutting down
+func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		finish(time.Now())
+	}
+}
+
+// Returns a copy of the recent entries, newest first
+func Recent() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+	entries := make([]Entry, len(recent))
+	for i, e := range recent {
+		entries[len(recent)-1-i] = e
+	}
+	return entries
+}
+
+// END API ****************************************************
+
+const (
+	kDefaultMinLock = 5 * time.Second
+	kRecentEntries  = 200 // kept in memory for the UI
+)
+
+// mu guards everything below
+var (
+	mu      sync.Mutex
+	lbCfg   LbConfig
+	current *Entry  // the station being received
+	recent  []Entry // oldest first
+)
+
+// Fills in whatever is known, and raises the peak MER
+func (e *Entry) update(data lmClient.LongmyndData) {
+	set := func(field *string, value string) {
+		if value != lmClient.NoValue {
+			*field = value
+		}
+	}
+	set(&e.Frequency, data.Frequency)
+	set(&e.SymbolRate, data.SymbolRate)
+	set(&e.Mode, data.Mode)
+	set(&e.Constellation, data.Constellation)
+	set(&e.Fec, data.Fec)
+	set(&e.VideoCodec, data.VideoCodec)
+	set(&e.AudioCodec, data.AudioCodec)
+	if mhz, err := strconv.ParseFloat(e.Frequency, 64); err == nil {
+		e.Channel = spectrumClient.FrequencyChannel(mhz)
+	}
+	if mer, err := strconv.ParseFloat(data.DbMer, 64); err == nil && mer > e.PeakMer {
+		e.PeakMer = mer
+	}
+}
+
+// Ends the current entry and logs it if the lock lasted long enough. Must be called with mu held
+func finish(now time.Time) {
+	entry := *current
+	current = nil
+	entry.Duration = now.Sub(entry.Start).Round(time.Second)
+	if entry.Duration < lbCfg.MinLock {
+		return
+	}
+	recent = append(recent, entry)
+	if len(recent) > kRecentEntries {
+		recent = recent[len(recent)-kRecentEntries:]
+	}
+	qLog.Info("Logbook: %v %v on channel %v for %v", entry.Provider, entry.Service, entry.Channel, entry.Duration)
+	if err := appendEntry(lbCfg.File, entry); err != nil {
+		qLog.Error("Failed to write the logbook: %v", err)
+	}
+}
+
+// Appends one line of JSON to the logbook file
+func appendEntry(path string, entry Entry) error {
+	if path == "" {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Returns the last kRecentEntries of the logbook file, oldest first
+func loadRecent(path string) []Entry {
+	entries, err := readAll(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			qLog.Warn("Failed to read the logbook: %v", err)
+		}
+		return nil
+	}
+	if len(entries) > kRecentEntries {
+		entries = entries[len(entries)-kRecentEntries:]
+	}
+	return entries
+}
+
+// Returns every entry in the logbook file, oldest first, skipping bad lines
+func readAll(path string) ([]Entry, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			qLog.Warn("Logbook line %v skipped: %v", line, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
@@ -22,15 +22,27 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"math"
 	"os"
 	"os/exec"
 	"os/signal"
+	"q100receiver-bookworm/alignment"
 	"q100receiver-bookworm/lmClient"
+	"q100receiver-bookworm/logbook"
 	"q100receiver-bookworm/rxControl"
+	"q100receiver-bookworm/scheduler"
+	"q100receiver-bookworm/settings"
 	"q100receiver-bookworm/spectrumClient"
+	"q100receiver-bookworm/sysinfo"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ea7kir/qLog"
@@ -41,6 +53,7 @@ import (
 	"gioui.org/font/gofont"
 	"gioui.org/layout"
 	"gioui.org/op"
+	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/text"
 	"gioui.org/unit"
@@ -52,24 +65,63 @@ import (
 // application directory for the configuration data
 const lmFolder = "/home/pi/Q100/"
 
+const logPath = lmFolder + "receiver.log"
+
+// set at build time with: go build -ldflags "-X main.version=$(git describe --tags --always)"
+var version = "dev"
+
 // configuration data
 var (
 	spConfig = spectrumClient.SpConfig{
 		// Url:    "wss://eshail.batc.org.uk/wb/fft/fft_ea7kirsatcontroller:443/",
 		// Origin: "http://eshail.batc.org.uk/wb",
-		Origin: "https://eshail.batc.org.uk/",
-		Url:    "wss://eshail.batc.org.uk/wb/fft/fft_ea7kirsatcontroller:443/wss",
+		Origin:        "https://eshail.batc.org.uk/",
+		Url:           "wss://eshail.batc.org.uk/wb/fft/fft_ea7kirsatcontroller:443/wss",
+		BeaconHistory: 4 * time.Hour,
+		BeaconAlarmDb: 6, // dB above the noise floor
 	}
 	lmConfig = lmClient.LmConfig{
 		Folder:     lmFolder + "longmynd/",
 		Binary:     lmFolder + "longmynd/longmynd",
 		Offset:     float64(9750000),
+		OffsetFile: lmFolder + "lnb_offset",
+		AgcFile:    lmFolder + "agc_calibration", // for this MiniTiouner
 		StatusFifo: lmFolder + "longmynd/longmynd_main_status",
+		Options: lmClient.LmOptions{
+			ScanWidth: 0.6,
+		},
 	}
 	fpConfig = lmClient.FpConfig{
-		Binary: "/usr/bin/ffplay",
+		Player: lmClient.PlayerFfplay, // or PlayerMpv or PlayerVlc
+		Binary: "",                    // empty for the binary of the Player
 		TsFifo: lmFolder + "longmynd/longmynd_main_ts",
 		Volume: "100",
+		Left:   800, // fullscreen on the HDMI display to the right of the touch screen
+		Embed:  false,
+	}
+	alConfig = alignment.AlConfig{
+		ToneBinary: "/usr/bin/aplay", // empty for no tone
+	}
+	lbConfig = logbook.LbConfig{
+		File:            lmFolder + "logbook.jsonl",
+		StationCallsign: "EA7KIR",
+	}
+	scConfig = scheduler.ScConfig{
+		File:         lmFolder + "schedule", // see scheduler/parser.go for the format
+		RecordFolder: lmFolder + "recordings",
+	}
+	stConfig = settings.StConfig{
+		File:   lmFolder + "settings.json", // overrides the configuration above
+		Themes: themeNames(),               // the first is the default
+		Urls: []string{
+			"wss://eshail.batc.org.uk/wb/fft/fft_ea7kirsatcontroller:443/wss",
+			"wss://eshail.batc.org.uk/wb/fft",
+		},
+	}
+	siConfig = sysinfo.SiConfig{
+		Version:        version,
+		LogFile:        logPath,
+		LongmyndFolder: lmFolder + "longmynd/",
 	}
 	tuConfig = rxControl.TuConfig{
 		Band:                 "Narrow",
@@ -79,6 +131,15 @@ var (
 		WideFrequency:        "10494.75 / 09",
 		NarrowFrequency:      "10499.25 / 27",
 		VeryNarrowFrequency:  "10496.00 / 14",
+		Lnb:                  lmClient.LnbStatusOff,
+		FavouritesFile:       lmFolder + "favourites.json",
+		Afc:                  false, // or true to follow drifting transmitters
+		BandOptions:          map[string]lmClient.LmOptions{
+			// eg. for the dish feed on the B input
+			// "Narrow": {InputB: true, ScanWidth: 0.6},
+			// eg. to let longmynd try all the very narrow symbol rates
+			// "V.Narrow": {ScanWidth: 0.6, SymbolRates: []string{"33", "66", "125"}},
+		},
 	}
 )
 
@@ -88,13 +149,14 @@ var (
 	spChannel = make(chan spectrumClient.SpData) //, 5)
 	lmData    lmClient.LongmyndData
 	lmChannel = make(chan lmClient.LongmyndData) //, 5)
+	rxState   rxControl.State
 )
 
 // main - with some help from Chris Waldon who got me started
 func main() {
 	// qLog.Open("mylog.txt")
 
-	logFile, err := os.OpenFile("/home/pi/Q100/receiver.log", os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		fmt.Println("failed to open log file:", err)
 		os.Exit(1)
@@ -104,17 +166,27 @@ func main() {
 	qLog.SetOutput(logFile)
 	defer qLog.Close()
 
-	qLog.Info("----- q100receiver Opened -----")
+	qLog.Info("----- q100receiver %v Opened -----", version)
 
 	// os.Setenv("WAYLAND_DISPLAY", ":0")		// this work
 	os.Setenv("WAYLAND_DISPLAY", "wayland-1") // this work - also from ssh cli
 
+	settings.Intitialize(stConfig, &spConfig, &lmConfig, &fpConfig, &tuConfig)
+
 	spectrumClient.Intitialize(spConfig, spChannel)
 
 	rxControl.Intitialize(tuConfig)
 
 	lmClient.Intitialize(lmConfig, fpConfig, lmChannel)
 
+	alignment.Intitialize(alConfig)
+
+	logbook.Intitialize(lbConfig)
+
+	scheduler.Intitialize(scConfig)
+
+	sysinfo.Intitialize(siConfig)
+
 	go func() {
 		// w := app.NewWindow(app.Fullscreen.Option())
 		app.Size(800, 480) // I don't know if this is help in any way
@@ -126,27 +198,97 @@ func main() {
 			os.Exit(1)
 		}
 
+		stopReceiver()
+
+		qLog.Info("----- q100receiver Closed -----")
+		os.Exit(0)
+	}()
+
+	app.Main()
+}
+
+// what to do when the receiver is shut down
+type exitAction int
+
+const (
+	exitToDesktop exitAction = iota
+	exitRestart
+	exitReboot
+	exitPowerOff
+	numExitActions
+)
+
+func (a exitAction) String() string {
+	switch a {
+	case exitRestart:
+		return "Restart app"
+	case exitReboot:
+		return "Reboot"
+	case exitPowerOff:
+		return "Power off"
+	}
+	return "Exit to desktop"
+}
+
+var stopOnce sync.Once
+
+// Stops everything that was started by main. Safe to call more than once
+func stopReceiver() {
+	stopOnce.Do(func() {
 		// TODO: implement with a d/on channel
+		alignment.Stop()
+		scheduler.Stop()
+		logbook.Stop()
 		rxControl.Stop()
 		lmClient.Stop()
 		spectrumClient.Stop()
+	})
+}
 
-		if !true { // change to true for powerdown
-			qLog.Info("----- q100receiver will poweroff -----")
-			time.Sleep(1 * time.Second)
-			cmd := exec.Command("sudo", "poweroff")
-			if err := cmd.Start(); err != nil {
-				qLog.Error("failed to poweroff: %v", err)
-				os.Exit(1)
-			}
-			cmd.Wait()
+// Stops the receiver and then restarts it, reboots or powers off
+//
+//	returns nil when the UI should exit, otherwise the error. Called as a go routine from the shutdown dialog.
+//	What can fail is checked first, so the receiver keeps running when it does
+func shutdown(action exitAction) error {
+	qLog.Info("----- q100receiver will %v -----", strings.ToLower(action.String()))
+	switch action {
+	case exitRestart:
+		exe, err := os.Executable()
+		if err != nil {
+			return err
 		}
-
+		stopReceiver()
 		qLog.Info("----- q100receiver Closed -----")
-		os.Exit(0)
-	}()
+		return syscall.Exec(exe, os.Args, os.Environ()) // only returns on failure
+	case exitReboot, exitPowerOff:
+		command := "reboot"
+		if action == exitPowerOff {
+			command = "poweroff"
+		}
+		if err := runSudo("-l", command); err != nil {
+			return fmt.Errorf("sudo is not allowed to %v: %v", command, err)
+		}
+		stopReceiver()
+		if err := runSudo(command); err != nil {
+			return fmt.Errorf("%v, and the receiver has stopped, so restart the app", err)
+		}
+		return nil
+	}
+	stopReceiver()
+	return nil
+}
 
-	app.Main()
+// Runs a system command with sudo, without asking for a password.
+// With "-l" first, only checks that the command is allowed
+func runSudo(args ...string) error {
+	out, err := exec.Command("sudo", append([]string{"-n"}, args...)...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %v", err, msg)
+		}
+		return err
+	}
+	return nil
 }
 
 func loop(w *app.Window) error {
@@ -159,6 +301,12 @@ func loop(w *app.Window) error {
 	// Chris says keep using the original font
 	ui.th.Shaper = text.NewShaper(text.NoSystemFonts(), text.WithCollection(gofont.Collection()))
 
+	rxEvents, unsubscribe := rxControl.Subscribe()
+	defer unsubscribe()
+	rxState = rxControl.Snapshot()
+
+	exitDone := make(chan error, 1)
+
 	var ops op.Ops
 	// Capture the context done channel in a variable so that we can nil it
 	// out after it closes and prevent its select case from firing again.
@@ -173,10 +321,22 @@ func loop(w *app.Window) error {
 			done = nil
 			return nil
 			// w.Perform(system.ActionClose)
+		case err := <-exitDone:
+			if err == nil {
+				return nil
+			}
+			qLog.Error("Failed to %v: %v", strings.ToLower(ui.exitAction.String()), err)
+			ui.shutdownMsg = fmt.Sprintf("%v failed: %v", ui.exitAction, err)
+			ui.stopping = false
+			w.Invalidate()
 		case lmData = <-lmChannel:
+			logbook.Update(lmData)
 			w.Invalidate()
 		case spData = <-spChannel:
 			w.Invalidate()
+		case ev := <-rxEvents:
+			rxState = ev.State
+			w.Invalidate()
 		}
 
 		switch event := w.Event().(type) {
@@ -184,30 +344,167 @@ func loop(w *app.Window) error {
 			return event.Err
 		case app.FrameEvent:
 			gtx := app.NewContext(&ops, event)
+			ui.q100_Scale(&gtx)
+			ui.q100_SelectTheme()
 			if ui.about.Clicked(gtx) {
-				showAboutBox()
+				ui.showAboutBox(!ui.aboutOpen)
+			}
+			if ui.dismiss.Clicked(gtx) {
+				ui.showAboutBox(false)
+				ui.showShutdownDialog(false)
+				ui.kb = nil
+			}
+			ui.q100_KeyboardClicks(gtx)
+			if ui.aboutClose.Clicked(gtx) {
+				ui.showAboutBox(false)
+			}
+			if ui.aboutRefresh.Clicked(gtx) {
+				sysinfo.Refresh()
+			}
+			if ui.lnb.Clicked(gtx) {
+				rxControl.NextLnb()
+			}
+			if ui.calibrate.Clicked(gtx) {
+				rxControl.Calibrate()
+			}
+			if ui.align.Clicked(gtx) {
+				rxControl.StartAlignment()
+				alignment.Start()
+			}
+			if ui.alignTone.Clicked(gtx) {
+				alignment.ToggleTone()
+			}
+			if ui.alignReset.Clicked(gtx) {
+				alignment.ResetPeaks()
+			}
+			if ui.alignExit.Clicked(gtx) {
+				alignment.Stop()
+				rxControl.StopAlignment()
 			}
 			if ui.shutdown.Clicked(gtx) {
-				return nil
-				// w.Perform(system.ActionClose)
+				ui.showShutdownDialog(true)
+			}
+			if ui.shutdownCancel.Clicked(gtx) {
+				ui.showShutdownDialog(false)
+			}
+			for i := range ui.exitButtons {
+				if ui.exitButtons[i].Clicked(gtx) && !ui.stopping {
+					ui.exitAction = exitAction(i)
+					ui.stopping = true
+					ui.shutdownMsg = fmt.Sprintf("Stopping the receiver, then %v...", strings.ToLower(ui.exitAction.String()))
+					go func(action exitAction) { exitDone <- shutdown(action) }(ui.exitAction)
+				}
 			}
 			if ui.decBand.Clicked(gtx) {
-				rxControl.DecBandSelector(&rxControl.Band)
+				rxControl.DecBand()
 			}
 			if ui.incBand.Clicked(gtx) {
-				rxControl.IncBandSelector(&rxControl.Band)
+				rxControl.IncBand()
+			}
+			if ui.autoSymbolRate.Clicked(gtx) {
+				rxControl.AutoSymbolRate()
 			}
 			if ui.decSymbolRate.Clicked(gtx) {
-				rxControl.DecSelector(&rxControl.SymbolRate)
+				rxControl.DecSymbolRate()
 			}
 			if ui.incSymbolRate.Clicked(gtx) {
-				rxControl.IncSelector(&rxControl.SymbolRate)
+				rxControl.IncSymbolRate()
 			}
 			if ui.decFrequency.Clicked(gtx) {
-				rxControl.DecSelector(&rxControl.Frequency)
+				rxControl.DecFrequency()
 			}
 			if ui.incFrequency.Clicked(gtx) {
-				rxControl.IncSelector(&rxControl.Frequency)
+				rxControl.IncFrequency()
+			}
+			if ui.spectrumMode.Clicked(gtx) {
+				spectrumClient.NextMode()
+			}
+			if ui.spectrum.Clicked(gtx) {
+				spectrumClient.Reset()
+			}
+			if ui.matrix.Clicked(gtx) {
+				if ui.view >= numViews {
+					ui.view = viewSpectrum
+				} else {
+					ui.view = (ui.view + 1) % numViews
+				}
+			}
+			if ui.exportCsv.Clicked(gtx) {
+				ui.exportMsg = exportMessage(logbook.ExportCsv())
+			}
+			if ui.exportAdif.Clicked(gtx) {
+				path, skipped, err := logbook.ExportAdif()
+				ui.exportMsg = exportMessage(path, err)
+				if err == nil && skipped > 0 {
+					ui.exportMsg += fmt.Sprintf(", %v without a callsign skipped", skipped)
+				}
+			}
+			if ui.keypad.Clicked(gtx) {
+				if ui.view == viewKeypad {
+					ui.view = viewSpectrum
+				} else {
+					ui.view = viewKeypad
+					ui.keyMsg = ""
+				}
+			}
+			ui.q100_KeypadClicks(gtx)
+			if ui.settings.Clicked(gtx) {
+				if ui.view == viewSettings {
+					ui.view = viewSpectrum
+				} else {
+					ui.view = viewSettings
+					ui.edit = settings.Current()
+					ui.settingsMsg = ""
+				}
+			}
+			ui.q100_SettingsClicks(gtx)
+			if ui.favourites.Clicked(gtx) {
+				if ui.view == viewFavourites {
+					ui.view = viewSpectrum
+				} else {
+					ui.view = viewFavourites
+				}
+			}
+			if ui.saveFavourite.Clicked(gtx) {
+				ui.openKeyboard("Name the favourite, or leave it empty for its channel and symbol rate", "", false,
+					func(name string) error {
+						if err := rxControl.SaveFavourite(name); err != nil {
+							return err
+						}
+						ui.favouriteMsg = "Saved"
+						return nil
+					})
+			}
+			if ui.deleteFavourite.Clicked(gtx) {
+				ui.deleting = !ui.deleting
+				ui.renaming = false
+			}
+			if ui.renameFavourite.Clicked(gtx) {
+				ui.renaming = !ui.renaming
+				ui.deleting = false
+			}
+			for i := range ui.recall {
+				if !ui.recall[i].Clicked(gtx) {
+					continue
+				}
+				if ui.deleting {
+					ui.favouriteMsg = favouriteMessage("Deleted", rxControl.DeleteFavourite(i))
+					ui.deleting = false
+				} else if favs := rxControl.Favourites(); ui.renaming && i < len(favs) {
+					index := i
+					ui.openKeyboard("Rename the favourite", favs[i].Name, false, func(name string) error {
+						if err := rxControl.RenameFavourite(index, name); err != nil {
+							return err
+						}
+						ui.favouriteMsg = "Renamed"
+						return nil
+					})
+					ui.renaming = false
+				} else {
+					ui.favouriteMsg = favouriteMessage("Recalled", rxControl.Recall(i))
+					ui.view = viewSpectrum
+				}
+				break
 			}
 			if ui.tune.Clicked(gtx) {
 				rxControl.Tune()
@@ -215,6 +512,13 @@ func loop(w *app.Window) error {
 			if ui.stream.Clicked(gtx) {
 				rxControl.Stream()
 			}
+			rxState = rxControl.Snapshot()
+			if rxState.Aligning {
+				ui.q100_UpdateMeter()
+			}
+			if fpConfig.Embed && !ui.videoVisible() {
+				lmClient.SetVideoRegion(0, 0, 0, 0) // the spectrum, which it covers, sets it again
+			}
 
 			// gtx := layout.NewContext(&ops, event)
 			// set the screen background to dark grey
@@ -226,49 +530,444 @@ func loop(w *app.Window) error {
 	}
 }
 
-// custom color scheme
-var q100color = struct {
-	screenGrey                               color.NRGBA
+// custom color scheme, see kThemes
+type palette struct {
+	screenGrey, overlayGrey                  color.NRGBA
 	labelWhite, labelOrange                  color.NRGBA
+	labelGreen, labelYellow, labelRed        color.NRGBA
 	buttonGrey, buttonGreen, buttonRed       color.NRGBA
 	gfxBgd, gfxGreen, gfxGraticule, gfxLabel color.NRGBA
-	gfxBeacon, gfxMarker                     color.NRGBA
+	gfxBeacon, gfxMarker, gfxHeld, gfxPower  color.NRGBA
+}
+
+// the selectable themes, the first is the default
+var kThemes = []struct {
+	name    string
+	palette palette
 }{
-	// see: https://pkg.go.dev/golang.org/x/image/colornames
-	// but maybe I should just create my own colors
-	screenGrey:   color.NRGBA{R: 16, G: 16, B: 16, A: 255}, // no LightBlack
-	labelWhite:   color.NRGBA(colornames.White),
-	labelOrange:  color.NRGBA(colornames.Darkorange),       // or Orange or Darkorange or Gold
-	buttonGrey:   color.NRGBA{R: 32, G: 32, B: 32, A: 255}, // DarkGrey is too light
-	buttonGreen:  color.NRGBA(colornames.Green),
-	buttonRed:    color.NRGBA(colornames.Red),
-	gfxBgd:       color.NRGBA(colornames.Black),
-	gfxGreen:     color.NRGBA(colornames.Green),
-	gfxBeacon:    color.NRGBA(colornames.Red),
-	gfxMarker:    color.NRGBA{R: 20, G: 20, B: 20, A: 255},
-	gfxGraticule: color.NRGBA(colornames.Darkgray),
-	gfxLabel:     color.NRGBA{R: 32, G: 32, B: 32, A: 255}, // DarkGrey is too light
+	{"Default", palette{
+		// see: https://pkg.go.dev/golang.org/x/image/colornames
+		// but maybe I should just create my own colors
+		screenGrey:   color.NRGBA{R: 16, G: 16, B: 16, A: 255}, // no LightBlack
+		overlayGrey:  color.NRGBA{R: 0, G: 0, B: 0, A: 200},    // dims the screen behind a dialog
+		labelWhite:   color.NRGBA(colornames.White),
+		labelOrange:  color.NRGBA(colornames.Darkorange), // or Orange or Darkorange or Gold
+		labelGreen:   color.NRGBA(colornames.Limegreen),
+		labelYellow:  color.NRGBA(colornames.Gold),
+		labelRed:     color.NRGBA(colornames.Red),
+		buttonGrey:   color.NRGBA{R: 32, G: 32, B: 32, A: 255}, // DarkGrey is too light
+		buttonGreen:  color.NRGBA(colornames.Green),
+		buttonRed:    color.NRGBA(colornames.Red),
+		gfxBgd:       color.NRGBA(colornames.Black),
+		gfxGreen:     color.NRGBA(colornames.Green),
+		gfxBeacon:    color.NRGBA(colornames.Red),
+		gfxMarker:    color.NRGBA{R: 20, G: 20, B: 20, A: 255},
+		gfxHeld:      color.NRGBA(colornames.Yellow),
+		gfxPower:     color.NRGBA(colornames.Deepskyblue),
+		gfxGraticule: color.NRGBA(colornames.Darkgray),
+		gfxLabel:     color.NRGBA{R: 32, G: 32, B: 32, A: 255}, // DarkGrey is too light
+	}},
+	{"High contrast", palette{
+		// for sunlight, bright values on black with lighter buttons
+		screenGrey:   color.NRGBA(colornames.Black),
+		overlayGrey:  color.NRGBA{R: 0, G: 0, B: 0, A: 220},
+		labelWhite:   color.NRGBA(colornames.White),
+		labelOrange:  color.NRGBA(colornames.Yellow),
+		labelGreen:   color.NRGBA(colornames.Lime),
+		labelYellow:  color.NRGBA(colornames.Orange),
+		labelRed:     color.NRGBA{R: 255, G: 64, B: 64, A: 255},
+		buttonGrey:   color.NRGBA{R: 70, G: 70, B: 70, A: 255},
+		buttonGreen:  color.NRGBA{R: 0, G: 150, B: 0, A: 255},
+		buttonRed:    color.NRGBA{R: 200, G: 0, B: 0, A: 255},
+		gfxBgd:       color.NRGBA(colornames.Black),
+		gfxGreen:     color.NRGBA(colornames.Lime),
+		gfxBeacon:    color.NRGBA(colornames.Red),
+		gfxMarker:    color.NRGBA{R: 60, G: 60, B: 60, A: 255},
+		gfxHeld:      color.NRGBA(colornames.Yellow),
+		gfxPower:     color.NRGBA(colornames.Cyan),
+		gfxGraticule: color.NRGBA(colornames.White),
+		gfxLabel:     color.NRGBA(colornames.Lightgray),
+	}},
+	{"Night", palette{
+		// only red, to keep night vision
+		screenGrey:   color.NRGBA(colornames.Black),
+		overlayGrey:  color.NRGBA{R: 0, G: 0, B: 0, A: 220},
+		labelWhite:   color.NRGBA{R: 150, G: 0, B: 0, A: 255},
+		labelOrange:  color.NRGBA{R: 220, G: 0, B: 0, A: 255},
+		labelGreen:   color.NRGBA{R: 110, G: 0, B: 0, A: 255},
+		labelYellow:  color.NRGBA{R: 200, G: 40, B: 0, A: 255},
+		labelRed:     color.NRGBA{R: 255, G: 0, B: 0, A: 255},
+		buttonGrey:   color.NRGBA{R: 25, G: 0, B: 0, A: 255},
+		buttonGreen:  color.NRGBA{R: 70, G: 0, B: 0, A: 255},
+		buttonRed:    color.NRGBA{R: 110, G: 0, B: 0, A: 255},
+		gfxBgd:       color.NRGBA(colornames.Black),
+		gfxGreen:     color.NRGBA{R: 110, G: 0, B: 0, A: 255},
+		gfxBeacon:    color.NRGBA{R: 255, G: 0, B: 0, A: 255},
+		gfxMarker:    color.NRGBA{R: 25, G: 0, B: 0, A: 255},
+		gfxHeld:      color.NRGBA{R: 200, G: 40, B: 0, A: 255},
+		gfxPower:     color.NRGBA{R: 160, G: 0, B: 60, A: 255},
+		gfxGraticule: color.NRGBA{R: 90, G: 0, B: 0, A: 255},
+		gfxLabel:     color.NRGBA{R: 40, G: 0, B: 0, A: 255},
+	}},
+}
+
+// the colors of the selected theme
+var q100color = kThemes[0].palette
+
+// Returns the names of the themes
+func themeNames() []string {
+	var names []string
+	for _, t := range kThemes {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+// Selects the theme from the settings, or the one being edited in the settings view so that it can be seen
+func (ui *UI) q100_SelectTheme() {
+	name := settings.Current().Theme
+	if ui.view == viewSettings {
+		name = ui.edit.Theme
+	}
+	q100color = kThemes[0].palette
+	for _, t := range kThemes {
+		if t.name == name {
+			q100color = t.palette
+		}
+	}
+	ui.th.Palette.Fg = q100color.labelWhite
+	ui.th.Palette.Bg = q100color.screenGrey
+	ui.th.Palette.ContrastBg = q100color.buttonGreen
+	ui.th.Palette.ContrastFg = q100color.labelWhite
 }
 
 // define all buttons
 type UI struct {
-	about, shutdown              widget.Clickable
+	about, lnb, calibrate, align widget.Clickable
+	aboutClose, aboutRefresh     widget.Clickable
+	aboutOpen                    bool
+	dismiss                      widget.Clickable // outside a dialog
+	shutdownOpen, stopping       bool
+	exitButtons                  [numExitActions]widget.Clickable
+	shutdownCancel               widget.Clickable
+	exitAction                   exitAction
+	shutdownMsg                  string
+	alignTone, alignReset        widget.Clickable
+	alignExit                    widget.Clickable
+	meter                        alignment.Meter
+	shutdown                     widget.Clickable
 	decBand, incBand             widget.Clickable
 	decSymbolRate, incSymbolRate widget.Clickable
+	autoSymbolRate               widget.Clickable
 	decFrequency, incFrequency   widget.Clickable
 	tune, stream                 widget.Clickable
+	spectrum, spectrumMode       widget.Clickable
+	matrix                       widget.Clickable
+	view                         view
+	streams                      widget.List
+	logbook                      widget.List
+	schedule                     widget.List
+	exportCsv, exportAdif        widget.Clickable
+	exportMsg                    string
+	favourites, saveFavourite    widget.Clickable
+	deleteFavourite              widget.Clickable
+	renameFavourite              widget.Clickable
+	renaming                     bool
+	recall                       []widget.Clickable // one for each favourite
+	favouriteList                widget.List
+	deleting                     bool
+	favouriteMsg                 string
+	keypad                       widget.Clickable
+	keys                         [len(kKeypadKeys)]widget.Clickable
+	keyFields                    [2]widget.Clickable // frequency and symbol rate
+	keyEnter, keyClear           widget.Clickable
+	fineDec, fineInc             widget.Clickable
+	afc                          widget.Clickable
+	keyField                     int
+	keyText                      [2]string
+	keyMsg                       string
+	settings                     widget.Clickable
+	settingDec, settingInc       [len(kSettingRows)]widget.Clickable
+	settingEntry                 [len(kSettingRows)]widget.Clickable // for the rows that can be typed
+	settingsApply, settingsUndo  widget.Clickable
+	calibratePower               widget.Clickable
+	edit                         settings.Settings // a copy, until applied
+	settingsList                 widget.List       // in portrait
+	settingsMsg                  string
+	kb                           *keyboard // nil when the keyboard is closed
+	kbKeys                       [len(kKeyboardKeys)]widget.Clickable
+	kbSpace, kbOk, kbCancel      widget.Clickable
 	th                           *material.Theme
+	topRowHeight                 int  // used to find the position of embedded video
+	portrait                     bool // the window is taller than it is wide
 }
 
+// the views shown in place of the spectrum
+type view int
+
+const (
+	viewSpectrum view = iota
+	viewSignalGraph
+	viewStreams
+	viewLogbook
+	viewSchedule
+	numViews       // views above are cycled by touching the status matrix
+	viewFavourites // shown by the FAV button
+	viewKeypad     // shown by the KEY button
+	viewSettings   // shown by the SET button
+)
+
 // makes the code more readable
 type (
 	C = layout.Context
 	D = layout.Dimensions
 )
 
-// Returns an About box
-func showAboutBox() {
-	// TODO: implement an about box
+// the window size in Dp that the layout was designed for, ie. the official 7" display
+const kDesignWidth, kDesignHeight = 800, 480
+
+// the margin each side of the main view, which fills the space between the rows
+const kViewMargin = 6
+
+// Scales the UI to fill the window, whatever its size, DPI and orientation
+//
+//	the layout is designed for kDesignWidth x kDesignHeight Dp, or the other way round in portrait.
+//	The system font scale is kept
+func (ui *UI) q100_Scale(gtx *C) {
+	size := gtx.Constraints.Max
+	ui.portrait = size.Y > size.X
+	width, height := float32(kDesignWidth), float32(kDesignHeight)
+	if ui.portrait {
+		width, height = height, width
+	}
+	scale := min(float32(size.X)/width, float32(size.Y)/height)
+	if scale <= 0 {
+		return
+	}
+	fontScale := float32(1)
+	if gtx.Metric.PxPerDp > 0 {
+		fontScale = gtx.Metric.PxPerSp / gtx.Metric.PxPerDp
+	}
+	gtx.Metric.PxPerDp = scale
+	gtx.Metric.PxPerSp = scale * fontScale
+}
+
+// Returns the size of the main view, the space between the rows less a margin each side
+func viewSize(gtx C) image.Point {
+	return image.Point{X: gtx.Constraints.Max.X - 2*gtx.Dp(kViewMargin), Y: gtx.Constraints.Max.Y}
+}
+
+// Returns true if the embedded video can be shown, ie. the spectrum is on screen with no dialog over it
+func (ui *UI) videoVisible() bool {
+	return ui.view == viewSpectrum && !rxState.Aligning && !ui.aboutOpen && !ui.shutdownOpen && ui.kb == nil
+}
+
+// Shows or hides the About box, collecting the system information when shown
+func (ui *UI) showAboutBox(show bool) {
+	ui.aboutOpen = show
+	if show {
+		sysinfo.Refresh()
+	}
+}
+
+// Returns the About box with the system and build information we ask for when a problem is reported
+func (ui *UI) q100_AboutBox(gtx C) D {
+	info := sysinfo.Latest()
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+	row := func(label, value string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return layout.Flex{}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Dp(100)
+					return ui.q100_Label(gtx, label, q100color.labelWhite)
+				}),
+				layout.Flexed(1, func(gtx C) D {
+					return ui.q100_Label(gtx, value, q100color.labelOrange)
+				}),
+			)
+		})
+	}
+	rows := []layout.FlexChild{
+		row("Version", fmt.Sprintf("%v  commit %v  %v", info.Version, info.Commit, info.GoVersion)),
+	}
+	if info.IsComplete {
+		temp := "CPU unknown"
+		if info.HasCpuTemp {
+			temp = fmt.Sprintf("CPU %.1f°C", info.CpuTemp)
+		}
+		rows = append(rows,
+			row("Uptime", fmt.Sprintf("system %v  receiver %v", uptimeString(info.Uptime), uptimeString(info.Collected.Sub(info.Started)))),
+			row("Network", strings.Join(info.Addresses, "  ")),
+			row("Pi", temp+"  throttling "+info.Throttling),
+			row("longmynd", programString(info.Longmynd)),
+			row("Player", programString(info.Player)),
+			row("Spectrum", connectionString(info.Spectrum, info.Collected)),
+			row("Calibration", calibrationString()),
+			row("Log file", info.LogFile),
+		)
+	}
+	status := "Touch outside to close"
+	if info.IsRefreshing {
+		status = "Collecting..."
+	}
+	rows = append(rows, layout.Rigid(func(gtx C) D {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return inset.Layout(gtx, func(gtx C) D {
+					return ui.q100_Button(gtx, &ui.aboutRefresh, "Refresh", info.IsRefreshing, q100color.buttonGreen)
+				})
+			}),
+			layout.Rigid(func(gtx C) D {
+				return inset.Layout(gtx, func(gtx C) D {
+					return ui.q100_Button(gtx, &ui.aboutClose, "Close", false, q100color.buttonGrey)
+				})
+			}),
+			layout.Flexed(1, func(gtx C) D {
+				return ui.q100_Label(gtx, status, q100color.labelWhite)
+			}),
+		)
+	}))
+
+	size := dialogSize(gtx, 760, 450)
+	gtx.Constraints = layout.Exact(size)
+	paint.FillShape(gtx.Ops, q100color.buttonGrey, clip.Rect{Max: size}.Op())
+	return layout.UniformInset(8).Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+	})
+}
+
+// Shows or hides the shutdown dialog, which stays open while the receiver is stopping
+func (ui *UI) showShutdownDialog(show bool) {
+	if ui.stopping {
+		return
+	}
+	ui.shutdownOpen = show
+	ui.shutdownMsg = ""
+}
+
+// Returns the shutdown dialog, so that an accidental touch of the Shutdown button is harmless
+func (ui *UI) q100_ShutdownDialog(gtx C) D {
+	const btnWidth, btnHeight = 170, 60
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+	button := func(btn *widget.Clickable, label string, active bool, activeColor color.NRGBA) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				gtx.Constraints.Min.Y = gtx.Dp(btnHeight)
+				return ui.q100_Button(gtx, btn, label, active, activeColor)
+			})
+		})
+	}
+	var actions []layout.FlexChild
+	for i := range ui.exitButtons {
+		action := exitAction(i)
+		active, activeColor := false, q100color.buttonGreen
+		switch {
+		case ui.stopping:
+			active = action == ui.exitAction
+		case action == exitPowerOff:
+			active, activeColor = true, q100color.buttonRed
+		}
+		actions = append(actions, button(&ui.exitButtons[i], action.String(), active, activeColor))
+	}
+	msg := ui.shutdownMsg
+	if msg == "" {
+		msg = "The receiver will stop first"
+	}
+
+	size := dialogSize(gtx, 760, 260)
+	gtx.Constraints = layout.Exact(size)
+	paint.FillShape(gtx.Ops, q100color.buttonGrey, clip.Rect{Max: size}.Op())
+	return layout.UniformInset(8).Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical, Spacing: layout.SpaceEvenly}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return ui.q100_Label(gtx, "Shut down the receiver?", q100color.labelWhite)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if ui.portrait {
+					// 2 rows of 2
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(func(gtx C) D {
+							return layout.Flex{Spacing: layout.SpaceEvenly}.Layout(gtx, actions[:2]...)
+						}),
+						layout.Rigid(func(gtx C) D {
+							return layout.Flex{Spacing: layout.SpaceEvenly}.Layout(gtx, actions[2:]...)
+						}),
+					)
+				}
+				return layout.Flex{Spacing: layout.SpaceEvenly}.Layout(gtx, actions...)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					button(&ui.shutdownCancel, "Cancel", false, q100color.buttonGrey),
+					layout.Flexed(1, func(gtx C) D {
+						return ui.q100_Label(gtx, msg, q100color.labelOrange)
+					}),
+				)
+			}),
+		)
+	})
+}
+
+// Returns the size of a dialog, no larger than the window less a margin
+func dialogSize(gtx C, width, height unit.Dp) image.Point {
+	margin := 2 * gtx.Dp(kViewMargin)
+	return image.Point{
+		X: min(gtx.Dp(width), gtx.Constraints.Max.X-margin),
+		Y: min(gtx.Dp(height), gtx.Constraints.Max.Y-margin),
+	}
+}
+
+// Returns the beacon error found when the spectrum calibration was verified
+func calibrationString() string {
+	errorKHz, ok := spectrumClient.CalibrationError()
+	if !ok {
+		return "not yet verified"
+	}
+	return fmt.Sprintf("beacon error %+.0f KHz", errorKHz)
+}
+
+// Returns a duration as days, hours and minutes
+func uptimeString(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	if days > 0 {
+		return fmt.Sprintf("%vd %vh %vm", days, hours, minutes)
+	}
+	return fmt.Sprintf("%vh %vm", hours, minutes)
+}
+
+// Returns the version and PID of a program
+func programString(p sysinfo.Program) string {
+	pid := "not running"
+	if p.Pid != 0 {
+		pid = fmt.Sprintf("PID %v", p.Pid)
+	}
+	return fmt.Sprintf("%v  %v  %v", p.Version, pid, p.Binary)
+}
+
+// Returns the state of the spectrum connection
+func connectionString(c spectrumClient.Connection, now time.Time) string {
+	if !c.Connected {
+		return "disconnected from " + c.Url
+	}
+	frame := "no frames"
+	if !c.LastFrame.IsZero() {
+		frame = fmt.Sprintf("last frame %.1fs ago", now.Sub(c.LastFrame).Seconds())
+	}
+	return fmt.Sprintf("connected %v ago, %v", uptimeString(now.Sub(c.Since)), frame)
 }
 
 // Return a customisable button
@@ -304,7 +1003,8 @@ func (ui *UI) q100_Label(gtx C, label string, txtColor color.NRGBA) D {
 	return inset.Layout(gtx, lbl.Layout)
 }
 
-// Returns 1 row of 2 buttons and a label for About, Status and Shutdown
+// Returns 1 row of 7 buttons and a label for About, Status, Calibrate, Keypad, Align, Settings, LNB and Shutdown,
+// or 2 rows in portrait
 func (ui *UI) q100_TopStatusRow(gtx C) D {
 	const btnWidth = 30
 	inset := layout.Inset{
@@ -314,17 +1014,55 @@ func (ui *UI) q100_TopStatusRow(gtx C) D {
 		Right:  4,
 	}
 
-	return layout.Flex{
-		Alignment: layout.Middle,
-	}.Layout(gtx,
+	about := layout.Rigid(func(gtx C) D {
+		return inset.Layout(gtx, func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+			return ui.q100_Button(gtx, &ui.about, "Q-100 Receiver", false, q100color.buttonGrey)
+		})
+	})
+	status := layout.Flexed(1, func(gtx C) D {
+		if rxState.Calibrating {
+			return ui.q100_Label(gtx, "Calibrating LNB offset on the beacon...", q100color.labelOrange)
+		}
+		if lmClient.RecordingPath() != "" {
+			return ui.q100_Label(gtx, "REC  "+lmData.StatusMsg, q100color.labelRed)
+		}
+		return ui.q100_Label(gtx, lmData.StatusMsg, q100color.labelOrange)
+	})
+	buttons := []layout.FlexChild{
 		layout.Rigid(func(gtx C) D {
 			return inset.Layout(gtx, func(gtx C) D {
 				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
-				return ui.q100_Button(gtx, &ui.about, "Q-100 Receiver", false, q100color.buttonGrey)
+				return ui.q100_Button(gtx, &ui.calibrate, "CAL", rxState.Calibrating, q100color.buttonGreen)
 			})
 		}),
-		layout.Flexed(1, func(gtx C) D {
-			return ui.q100_Label(gtx, lmData.StatusMsg, q100color.labelOrange)
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.keypad, "KEY", ui.view == viewKeypad, q100color.buttonGreen)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.align, "ALIGN", false, q100color.buttonGrey)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.settings, "SET", ui.view == viewSettings, q100color.buttonGreen)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				label := "LNB " + rxState.LnbSupply
+				if rxState.Lnb == rxControl.LnbBandDefault {
+					label += " " + rxControl.LnbBandDefault
+				}
+				return ui.q100_Button(gtx, &ui.lnb, label, rxState.LnbSupply != lmClient.LnbStatusOff, q100color.buttonRed)
+			})
 		}),
 		layout.Rigid(func(gtx C) D {
 			return inset.Layout(gtx, func(gtx C) D {
@@ -332,7 +1070,24 @@ func (ui *UI) q100_TopStatusRow(gtx C) D {
 				return ui.q100_Button(gtx, &ui.shutdown, "Shutdown", false, q100color.buttonGrey)
 			})
 		}),
-	)
+	}
+
+	if ui.portrait {
+		// the buttons are below the status
+		return layout.Flex{
+			Axis: layout.Vertical,
+		}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx, about, status)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Spacing: layout.SpaceEvenly}.Layout(gtx, buttons...)
+			}),
+		)
+	}
+	return layout.Flex{
+		Alignment: layout.Middle,
+	}.Layout(gtx, append([]layout.FlexChild{about, status}, buttons...)...)
 }
 
 // Returns a single Selector as [ button label button ]
@@ -371,82 +1126,1118 @@ func (ui *UI) q100_Selector(gtx C, dec, inc *widget.Clickable, value string, btn
 	)
 }
 
-// Returns 1 row of 3 Selectors for Band SymbolRate and Frequency
-func (ui *UI) q100_MainTuningRow(gtx C) D {
-	const btnWidth = 50
+// Returns a Selector whose value can also be typed as [ button button button ], the middle one opens the keyboard
+func (ui *UI) q100_EntrySelector(gtx C, dec, inc, entry *widget.Clickable, value string, btnWidth, lblWidth unit.Dp) D {
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
 
 	return layout.Flex{
-		Axis:    layout.Horizontal,
-		Spacing: layout.SpaceEvenly,
+		Axis:      layout.Horizontal,
+		Alignment: layout.Middle,
 	}.Layout(gtx,
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_Selector(gtx, &ui.decBand, &ui.incBand, rxControl.Band.Value, btnWidth, 100)
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, dec, "<", false, q100color.buttonGrey)
+			})
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_Selector(gtx, &ui.decSymbolRate, &ui.incSymbolRate, rxControl.SymbolRate.Value, btnWidth, 50)
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(lblWidth)
+				return ui.q100_Button(gtx, entry, value, false, q100color.buttonGrey)
+			})
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_Selector(gtx, &ui.decFrequency, &ui.incFrequency, rxControl.Frequency.Value, btnWidth, 100)
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, inc, ">", false, q100color.buttonGrey)
+			})
 		}),
 	)
 }
 
+// Returns 1 row of 3 Selectors for Band SymbolRate and Frequency, and a button for the spectrum mode,
+// or 2 rows in portrait
+func (ui *UI) q100_MainTuningRow(gtx C) D {
+	const btnWidth = 50
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return ui.q100_Selector(gtx, &ui.decBand, &ui.incBand, rxState.Band, btnWidth, 100)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return ui.q100_Selector(gtx, &ui.decSymbolRate, &ui.incSymbolRate, rxState.SymbolRate, btnWidth, 50)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				return ui.q100_Button(gtx, &ui.autoSymbolRate, "AUTO", rxState.Detecting, q100color.buttonGreen)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return ui.q100_Selector(gtx, &ui.decFrequency, &ui.incFrequency, rxState.Frequency, btnWidth, 100)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(90)
+				return ui.q100_Button(gtx, &ui.spectrumMode, spData.Mode.String(), spData.Mode != spectrumClient.ModeLive, q100color.buttonGreen)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				return ui.q100_Button(gtx, &ui.favourites, "FAV", ui.view == viewFavourites, q100color.buttonGreen)
+			})
+		}),
+	}
+
+	row := layout.Flex{
+		Axis:    layout.Horizontal,
+		Spacing: layout.SpaceEvenly,
+	}
+	if ui.portrait {
+		// band and symbol rate above frequency
+		return layout.Flex{
+			Axis: layout.Vertical,
+		}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return row.Layout(gtx, children[:3]...)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return row.Layout(gtx, children[3:]...)
+			}),
+		)
+	}
+	return row.Layout(gtx, children...)
+}
+
 // Returns the Spectrum display
 //
+//	touch to reset the average or held trace
+//
 // see: github.com/ajstarks/giocanvas for docs
 func (ui *UI) q100_SpectrumDisplay(gtx C) D {
 	return layout.Flex{
 		Axis:    layout.Horizontal,
 		Spacing: layout.SpaceSides,
 	}.Layout(gtx,
-		layout.Rigid(
-			func(gtx layout.Context) layout.Dimensions {
+		layout.Rigid(func(gtx C) D {
+			return ui.spectrum.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				size := viewSize(gtx)
 				canvas := giocanvas.Canvas{
-					Width:   float32(788), //gtx.Constraints.Max.X), //float32(width),  //float32(gtx.Constraints.Max.X),
-					Height:  float32(250), //float32(hieght), //float32(500),
+					Width:   float32(size.X),
+					Height:  float32(size.Y),
 					Context: gtx,
 					Theme:   ui.th,
 				}
 				// fmt("  Canvas: %#v\n", canvas.Context.Constraints)
 
 				canvas.Background(q100color.gfxBgd)
+				if fpConfig.Embed {
+					// the video player is placed over the spectrum, which is centred below the top row
+					left := (gtx.Constraints.Max.X - int(canvas.Width)) / 2
+					lmClient.SetVideoRegion(left, ui.topRowHeight, int(canvas.Width), int(canvas.Height))
+					if lmClient.IsPlaying() {
+						return layout.Dimensions{
+							Size: image.Point{X: int(canvas.Width), Y: int(canvas.Height)},
+						}
+					}
+				}
 				// tuning marker
 				canvas.Rect(spData.MarkerCentre, 50, spData.MarkerWidth, 100, q100color.gfxMarker)
 				// polygon
 				canvas.Polygon(spectrumClient.Xp, spData.Yp, q100color.gfxGreen)
-				// graticule
-				const fyBase float32 = 3
-				const fyInc float32 = 5.88235
-				fy := fyBase
-				for y := 0; y < 17; y++ {
-					switch y {
-					case 15:
-						canvas.Text(1, fy, 1.5, "15dB", q100color.gfxLabel)
+				// held trace
+				for i := 1; i < len(spData.Held); i++ {
+					canvas.Line(spectrumClient.Xp[i-1], spData.Held[i-1], spectrumClient.Xp[i], spData.Held[i], 0.15, q100color.gfxHeld)
+				}
+				// graticule, in dB above the noise floor
+				for db := 0; float32(db) <= spectrumClient.DbRange; db++ {
+					fy := spectrumClient.DbToY(float32(db))
+					if db%5 == 0 && db > 0 {
+						canvas.Text(1, fy, 1.5, fmt.Sprintf("%vdB", db), q100color.gfxLabel)
 						canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
-					case 10:
-						canvas.Text(1, fy, 1.5, "10dB", q100color.gfxLabel)
-						canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
-					case 5:
-						canvas.Text(1, fy, 1.5, "5dB", q100color.gfxLabel)
-						canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
-					default:
+					} else {
 						canvas.HLine(5, fy, 94, 0.005, q100color.gfxGraticule)
 					}
-					fy += fyInc
+				}
+				// frequency axis, with a tick every 0.5 MHz and a label every MHz
+				for mhz := math.Ceil(spectrumClient.XToFrequency(0)*2) / 2; mhz < spectrumClient.XToFrequency(100); mhz += 0.5 {
+					x := spectrumClient.FrequencyToX(mhz)
+					if mhz == math.Trunc(mhz) {
+						canvas.VLine(x, 0, 2, 0.1, q100color.gfxGraticule)
+						canvas.TextMid(x, 2.5, 1.3, fmt.Sprintf("%.0f", mhz), q100color.gfxLabel)
+					} else {
+						canvas.VLine(x, 0, 1, 0.1, q100color.gfxGraticule)
+					}
+				}
+				// channel numbers
+				canvas.TextMid(spectrumClient.FrequencyToX(spectrumClient.ChannelFrequency(0)), 96, 1.3, "B", q100color.gfxLabel)
+				for ch := 1; ch <= 27; ch++ {
+					x := spectrumClient.FrequencyToX(spectrumClient.ChannelFrequency(ch))
+					canvas.TextMid(x, 96, 1.3, fmt.Sprintf("%02d", ch), q100color.gfxLabel)
 				}
 				// beacon level
 				canvas.HLine(5, spData.BeaconLevel, 94, 0.03, q100color.gfxBeacon)
+				ui.q100_BeaconTrend(&canvas)
 
 				return layout.Dimensions{
 					Size: image.Point{X: int(canvas.Width), Y: int(canvas.Height)},
 				}
-			},
-		),
+			})
+		}),
+	)
+}
+
+// Draws a graph of the beacon SNR history in the top right corner of the spectrum
+func (ui *UI) q100_BeaconTrend(canvas *giocanvas.Canvas) {
+	const left, bottom, width, height float32 = 72, 70, 22, 20
+	trend := spectrumClient.BeaconHistory()
+
+	textColor := q100color.gfxGraticule
+	if spData.BeaconAlarm {
+		textColor = q100color.gfxBeacon
+	}
+	canvas.Rect(left+width/2, bottom+height/2, width, height, q100color.gfxBgd)
+	canvas.HLine(left, bottom, width, 0.01, q100color.gfxGraticule)
+	span := time.Duration(trend.Capacity) * trend.Interval
+	spanStr := fmt.Sprintf("%.0fm", span.Minutes())
+	if span >= time.Hour {
+		spanStr = fmt.Sprintf("%.0fh", span.Hours())
+	}
+	canvas.Text(left+0.5, bottom+height-3, 1.3, fmt.Sprintf("Beacon %.1fdB  %v", spData.BeaconSnr, spanStr), textColor)
+	if spData.BeaconAlarm {
+		canvas.TextEnd(left+width-0.5, bottom+1, 1.3, "LOW", q100color.gfxBeacon)
+	}
+
+	if trend.Capacity < 2 {
+		return
+	}
+	step := width / float32(trend.Capacity-1)
+	x0 := left + width - step*float32(len(trend.Samples)-1)
+	yOf := func(snr float32) float32 {
+		return bottom + height*0.8*min(max(snr, 0), spectrumClient.DbRange)/spectrumClient.DbRange
+	}
+	for i := 1; i < len(trend.Samples); i++ {
+		x := x0 + step*float32(i)
+		canvas.Line(x-step, yOf(trend.Samples[i-1]), x, yOf(trend.Samples[i]), 0.1, q100color.gfxBeacon)
+	}
+}
+
+// Returns the view selected by touching the status matrix
+func (ui *UI) q100_MainView(gtx C) D {
+	switch ui.view {
+	case viewSignalGraph:
+		return ui.q100_SignalGraph(gtx)
+	case viewStreams:
+		return ui.q100_StreamList(gtx)
+	case viewLogbook:
+		return ui.q100_Logbook(gtx)
+	case viewSchedule:
+		return ui.q100_Schedule(gtx)
+	case viewFavourites:
+		return ui.q100_Favourites(gtx)
+	case viewKeypad:
+		return ui.q100_Keypad(gtx)
+	case viewSettings:
+		return ui.q100_Settings(gtx)
+	}
+	return ui.q100_SpectrumDisplay(gtx)
+}
+
+// Returns a scrollable list of the elementary streams, the same size as the spectrum
+func (ui *UI) q100_StreamList(gtx C) D {
+	streams := lmClient.ElementaryStreams()
+	ui.streams.Axis = layout.Vertical
+
+	return layout.Flex{
+		Axis:    layout.Horizontal,
+		Spacing: layout.SpaceSides,
+	}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			size := viewSize(gtx)
+			gtx.Constraints = layout.Exact(size)
+			paint.FillShape(gtx.Ops, q100color.gfxBgd, clip.Rect{Max: size}.Op())
+			if len(streams) == 0 {
+				return ui.q100_Label(gtx, "No elementary streams", q100color.labelWhite)
+			}
+			return material.List(ui.th, &ui.streams).Layout(gtx, len(streams), func(gtx C, i int) D {
+				es := streams[i]
+				return ui.q100_LabelValue(gtx, fmt.Sprintf("PID %v", es.Pid),
+					fmt.Sprintf("%v %v (type %v)", es.Kind, es.Codec, es.Type), q100color.labelOrange)
+			})
+		}),
+	)
+}
+
+// Returns the recent logbook entries with buttons to export them, the same size as the spectrum
+func (ui *UI) q100_Logbook(gtx C) D {
+	entries := logbook.Recent()
+	ui.logbook.Axis = layout.Vertical
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+
+	return layout.Flex{
+		Axis:    layout.Horizontal,
+		Spacing: layout.SpaceSides,
+	}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			size := viewSize(gtx)
+			gtx.Constraints = layout.Exact(size)
+			paint.FillShape(gtx.Ops, q100color.gfxBgd, clip.Rect{Max: size}.Op())
+			return layout.Flex{
+				Axis: layout.Vertical,
+			}.Layout(gtx,
+				layout.Flexed(1, func(gtx C) D {
+					if len(entries) == 0 {
+						return ui.q100_Label(gtx, "No stations logged", q100color.labelWhite)
+					}
+					return material.List(ui.th, &ui.logbook).Layout(gtx, len(entries), func(gtx C, i int) D {
+						e := entries[i]
+						return ui.q100_Label(gtx, fmt.Sprintf("%v  Ch %02d  %v  %vKS  %v %v  MER %.1f  %v",
+							e.Start.Format("02 Jan 15:04"), e.Channel, e.Provider, e.SymbolRate,
+							e.Constellation, e.Fec, e.PeakMer, e.Duration), q100color.labelOrange)
+					})
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{
+						Alignment: layout.Middle,
+					}.Layout(gtx,
+						layout.Rigid(func(gtx C) D {
+							return inset.Layout(gtx, func(gtx C) D {
+								return ui.q100_Button(gtx, &ui.exportCsv, "Export CSV", false, q100color.buttonGrey)
+							})
+						}),
+						layout.Rigid(func(gtx C) D {
+							return inset.Layout(gtx, func(gtx C) D {
+								return ui.q100_Button(gtx, &ui.exportAdif, "Export ADIF", false, q100color.buttonGrey)
+							})
+						}),
+						layout.Flexed(1, func(gtx C) D {
+							return ui.q100_Label(gtx, ui.exportMsg, q100color.labelWhite)
+						}),
+					)
+				}),
+			)
+		}),
+	)
+}
+
+// Returns the favourites as buttons to recall them, and buttons to save and delete, the same size as the spectrum
+func (ui *UI) q100_Favourites(gtx C) D {
+	favourites := rxControl.Favourites()
+	if len(ui.recall) != len(favourites) {
+		ui.recall = make([]widget.Clickable, len(favourites))
+	}
+	ui.favouriteList.Axis = layout.Vertical
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+	deleteLabel, renameLabel := "Delete", "Rename"
+	if ui.deleting {
+		deleteLabel = "Touch one to delete"
+	}
+	if ui.renaming {
+		renameLabel = "Touch one to rename"
+	}
+	touchColor := q100color.buttonRed // of the favourites while deleting or renaming
+	if ui.renaming {
+		touchColor = q100color.buttonGreen
+	}
+
+	return layout.Flex{
+		Axis:    layout.Horizontal,
+		Spacing: layout.SpaceSides,
+	}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			size := viewSize(gtx)
+			gtx.Constraints = layout.Exact(size)
+			paint.FillShape(gtx.Ops, q100color.gfxBgd, clip.Rect{Max: size}.Op())
+			return layout.Flex{
+				Axis: layout.Vertical,
+			}.Layout(gtx,
+				layout.Flexed(1, func(gtx C) D {
+					if len(favourites) == 0 {
+						return ui.q100_Label(gtx, "No favourites, touch Save Current to add one", q100color.labelWhite)
+					}
+					return material.List(ui.th, &ui.favouriteList).Layout(gtx, len(favourites), func(gtx C, i int) D {
+						fav := favourites[i]
+						label := fmt.Sprintf("%v   %v  %v  %vKS", fav.Name, fav.Band, fav.Frequency, fav.SymbolRate)
+						return inset.Layout(gtx, func(gtx C) D {
+							gtx.Constraints.Min.X = gtx.Constraints.Max.X
+							return ui.q100_Button(gtx, &ui.recall[i], label, ui.deleting || ui.renaming, touchColor)
+						})
+					})
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{
+						Alignment: layout.Middle,
+					}.Layout(gtx,
+						layout.Rigid(func(gtx C) D {
+							return inset.Layout(gtx, func(gtx C) D {
+								return ui.q100_Button(gtx, &ui.saveFavourite, "Save Current", false, q100color.buttonGrey)
+							})
+						}),
+						layout.Rigid(func(gtx C) D {
+							return inset.Layout(gtx, func(gtx C) D {
+								return ui.q100_Button(gtx, &ui.deleteFavourite, deleteLabel, ui.deleting, q100color.buttonRed)
+							})
+						}),
+						layout.Rigid(func(gtx C) D {
+							return inset.Layout(gtx, func(gtx C) D {
+								return ui.q100_Button(gtx, &ui.renameFavourite, renameLabel, ui.renaming, q100color.buttonGreen)
+							})
+						}),
+						layout.Flexed(1, func(gtx C) D {
+							return ui.q100_Label(gtx, ui.favouriteMsg, q100color.labelWhite)
+						}),
+					)
+				}),
+			)
+		}),
+	)
+}
+
+// the keys of the numeric keypad, in rows of 3
+var kKeypadKeys = [...]string{"7", "8", "9", "4", "5", "6", "1", "2", "3", ".", "0", "<"}
+
+const kFineStepKHz = 25
+
+// Handles the keypad and fine tune buttons
+func (ui *UI) q100_KeypadClicks(gtx C) {
+	for i := range ui.keyFields {
+		if ui.keyFields[i].Clicked(gtx) {
+			ui.keyField = i
+		}
+	}
+	for i := range ui.keys {
+		if !ui.keys[i].Clicked(gtx) {
+			continue
+		}
+		text := ui.keyText[ui.keyField]
+		switch key := kKeypadKeys[i]; {
+		case key == "<":
+			if len(text) > 0 {
+				text = text[:len(text)-1]
+			}
+		case key == "." && (ui.keyField == 1 || strings.Contains(text, ".")):
+			// symbol rates are whole numbers
+		case len(text) < 10:
+			text += key
+		}
+		ui.keyText[ui.keyField] = text
+	}
+	if ui.keyClear.Clicked(gtx) {
+		ui.keyText = [2]string{}
+		ui.keyField = 0
+		ui.keyMsg = ""
+	}
+	if ui.keyEnter.Clicked(gtx) {
+		if err := rxControl.TuneManual(ui.keyText[0], ui.keyText[1]); err != nil {
+			ui.keyMsg = err.Error()
+		} else {
+			ui.keyMsg = "Tuned"
+		}
+	}
+	if ui.fineDec.Clicked(gtx) {
+		rxControl.FineTune(-kFineStepKHz)
+	}
+	if ui.fineInc.Clicked(gtx) {
+		rxControl.FineTune(kFineStepKHz)
+	}
+	if ui.afc.Clicked(gtx) {
+		rxControl.ToggleAfc()
+	}
+}
+
+// Returns the numeric keypad for any frequency and symbol rate, and fine tune and AFC buttons, the same size as the spectrum
+func (ui *UI) q100_Keypad(gtx C) D {
+	const keyHeight = 44
+	keyWidth := unit.Dp(70)
+	if ui.portrait {
+		keyWidth = 60
+	}
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+	key := func(btn *widget.Clickable, label string, active bool) layout.Widget {
+		return func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(keyWidth)
+				gtx.Constraints.Min.Y = gtx.Dp(keyHeight)
+				return ui.q100_Button(gtx, btn, label, active, q100color.buttonGreen)
+			})
+		}
+	}
+	keyRow := func(row int) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return layout.Flex{}.Layout(gtx,
+				layout.Rigid(key(&ui.keys[row*3], kKeypadKeys[row*3], false)),
+				layout.Rigid(key(&ui.keys[row*3+1], kKeypadKeys[row*3+1], false)),
+				layout.Rigid(key(&ui.keys[row*3+2], kKeypadKeys[row*3+2], false)),
+			)
+		})
+	}
+	field := func(i int, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			text := ui.keyText[i]
+			if ui.keyField == i {
+				text += "_"
+			}
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(key(&ui.keyFields[i], label, ui.keyField == i)),
+				layout.Rigid(func(gtx C) D {
+					return ui.q100_Label(gtx, text, q100color.labelOrange)
+				}),
+			)
+		})
+	}
+	fine := "0 kHz"
+	if rxState.FineKHz != 0 {
+		fine = fmt.Sprintf("%+d kHz", rxState.FineKHz)
+	}
+	fields := layout.Rigid(func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			field(0, "MHz"),
+			field(1, "KS"),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{}.Layout(gtx,
+					layout.Rigid(key(&ui.keyClear, "Clear", false)),
+					layout.Rigid(key(&ui.keyEnter, "Tune", false)),
+				)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return ui.q100_Label(gtx, ui.keyMsg, q100color.labelWhite)
+			}),
+		)
+	})
+	keys := layout.Rigid(func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			keyRow(0), keyRow(1), keyRow(2), keyRow(3),
+		)
+	})
+	fineTune := layout.Rigid(func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return ui.q100_Label(gtx, "Fine tune", q100color.labelWhite)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(key(&ui.fineDec, fmt.Sprintf("-%v", kFineStepKHz), false)),
+					layout.Rigid(func(gtx C) D {
+						return ui.q100_Label(gtx, fine, q100color.labelOrange)
+					}),
+					layout.Rigid(key(&ui.fineInc, fmt.Sprintf("+%v", kFineStepKHz), false)),
+				)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return ui.q100_Label(gtx, rxState.Frequency+"  "+rxState.SymbolRate+" KS", q100color.labelOrange)
+			}),
+			layout.Rigid(key(&ui.afc, "AFC", rxState.Afc)),
+		)
+	})
+
+	return layout.Flex{
+		Axis:    layout.Horizontal,
+		Spacing: layout.SpaceSides,
+	}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			size := viewSize(gtx)
+			gtx.Constraints = layout.Exact(size)
+			paint.FillShape(gtx.Ops, q100color.gfxBgd, clip.Rect{Max: size}.Op())
+			if ui.portrait {
+				// fine tune below the keypad
+				return layout.Flex{
+					Axis:    layout.Vertical,
+					Spacing: layout.SpaceEvenly,
+				}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						return layout.Flex{Spacing: layout.SpaceEvenly}.Layout(gtx, fields, keys)
+					}),
+					fineTune,
+				)
+			}
+			return layout.Flex{
+				Spacing: layout.SpaceEvenly,
+			}.Layout(gtx, fields, keys, fineTune)
+		}),
+	)
+}
+
+// the keys of the on-screen keyboard, in rows of 10
+var kKeyboardKeys = [...]string{
+	"1", "2", "3", "4", "5", "6", "7", "8", "9", "0",
+	"Q", "W", "E", "R", "T", "Y", "U", "I", "O", "P",
+	"A", "S", "D", "F", "G", "H", "J", "K", "L", "<",
+	"Z", "X", "C", "V", "B", "N", "M", "-", ".", "/",
+}
+
+const kKeyboardMaxLen = 24
+
+// the on-screen keyboard, for the few values that cannot be chosen with buttons
+type keyboard struct {
+	title   string
+	text    string
+	msg     string
+	numeric bool                    // only digits, "-" and "."
+	enter   func(text string) error // the keyboard closes unless it returns an error
+}
+
+// Opens the on-screen keyboard
+func (ui *UI) openKeyboard(title, text string, numeric bool, enter func(text string) error) {
+	ui.kb = &keyboard{title: title, text: text, numeric: numeric, enter: enter}
+}
+
+// Handles the on-screen keyboard buttons
+func (ui *UI) q100_KeyboardClicks(gtx C) {
+	kb := ui.kb
+	if kb == nil {
+		return
+	}
+	for i := range ui.kbKeys {
+		if !ui.kbKeys[i].Clicked(gtx) {
+			continue
+		}
+		switch key := kKeyboardKeys[i]; {
+		case key == "<":
+			if len(kb.text) > 0 {
+				kb.text = kb.text[:len(kb.text)-1]
+			}
+		case kb.numeric && !strings.Contains("0123456789-.", key):
+			// letters are ignored
+		case len(kb.text) < kKeyboardMaxLen:
+			kb.text += key
+		}
+	}
+	if ui.kbSpace.Clicked(gtx) && !kb.numeric && len(kb.text) < kKeyboardMaxLen {
+		kb.text += " "
+	}
+	if ui.kbCancel.Clicked(gtx) {
+		ui.kb = nil
+		return
+	}
+	if ui.kbOk.Clicked(gtx) {
+		if err := kb.enter(strings.TrimSpace(kb.text)); err != nil {
+			kb.msg = err.Error()
+		} else {
+			ui.kb = nil
+		}
+	}
+}
+
+// Returns the on-screen keyboard as a dialog
+func (ui *UI) q100_Keyboard(gtx C) D {
+	const keyHeight = 48
+	kb := ui.kb
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+	key := func(btn *widget.Clickable, label string, weight float32) layout.FlexChild {
+		return layout.Flexed(weight, func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Constraints.Max.X
+				gtx.Constraints.Min.Y = gtx.Dp(keyHeight)
+				return ui.q100_Button(gtx, btn, label, false, q100color.buttonGreen)
+			})
+		})
+	}
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return ui.q100_Label(gtx, kb.title, q100color.labelWhite)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return ui.q100_Label(gtx, kb.text+"_", q100color.labelOrange)
+		}),
+	}
+	for row := 0; row < len(kKeyboardKeys)/10; row++ {
+		var keys []layout.FlexChild
+		for i := row * 10; i < row*10+10; i++ {
+			keys = append(keys, key(&ui.kbKeys[i], kKeyboardKeys[i], 1))
+		}
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return layout.Flex{}.Layout(gtx, keys...)
+		}))
+	}
+	rows = append(rows,
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{}.Layout(gtx,
+				key(&ui.kbSpace, "Space", 4),
+				key(&ui.kbCancel, "Cancel", 3),
+				key(&ui.kbOk, "OK", 3),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return ui.q100_Label(gtx, kb.msg, q100color.labelWhite)
+		}),
+	)
+
+	size := dialogSize(gtx, 760, 420)
+	gtx.Constraints = layout.Exact(size)
+	paint.FillShape(gtx.Ops, q100color.buttonGrey, clip.Rect{Max: size}.Op())
+	return layout.UniformInset(8).Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+	})
+}
+
+// a row of the settings view, changed by its < and > buttons
+type settingRow struct {
+	label string
+	value func(s settings.Settings) string
+	step  func(s *settings.Settings, dir int)           // dir is -1 or +1
+	typed func(s *settings.Settings, text string) error // nil if the value cannot be typed on the keyboard
+}
+
+var kSettingRows = [...]settingRow{
+	{"Volume", func(s settings.Settings) string { return fmt.Sprintf("%v", s.Volume) },
+		func(s *settings.Settings, dir int) {
+			s.Volume = min(max(s.Volume+5*dir, settings.MinVolume), settings.MaxVolume)
+		}, nil},
+	{"LNB offset", func(s settings.Settings) string { return fmt.Sprintf("%.0f KHz", s.LnbOffset) },
+		func(s *settings.Settings, dir int) { s.LnbOffset = stepLnbOffset(s.LnbOffset, dir) },
+		func(s *settings.Settings, text string) error {
+			offset, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return errors.New("not a number of KHz")
+			}
+			s.LnbOffset = offset
+			return nil
+		}},
+	{"Start band", func(s settings.Settings) string { return s.DefaultBand },
+		func(s *settings.Settings, dir int) { s.DefaultBand = cycle(rxControl.Bands(), s.DefaultBand, dir) }, nil},
+	{"Average", func(s settings.Settings) string { return fmt.Sprintf("%.2f", s.AverageFactor) },
+		func(s *settings.Settings, dir int) {
+			s.AverageFactor = stepFloat(s.AverageFactor, 0.05*float32(dir), settings.MinAverageFactor, settings.MaxAverageFactor)
+		}, nil},
+	{"Hold decay", func(s settings.Settings) string { return fmt.Sprintf("%.2f dB", s.HoldDecay) },
+		func(s *settings.Settings, dir int) {
+			s.HoldDecay = stepFloat(s.HoldDecay, 0.01*float32(dir), settings.MinHoldDecay, settings.MaxHoldDecay)
+		}, nil},
+	{"Beacon alarm", func(s settings.Settings) string {
+		if s.BeaconAlarmDb == 0 {
+			return "Off"
+		}
+		return fmt.Sprintf("%.1f dB", s.BeaconAlarmDb)
+	},
+		func(s *settings.Settings, dir int) {
+			s.BeaconAlarmDb = stepFloat(s.BeaconAlarmDb, 0.5*float32(dir), settings.MinBeaconAlarmDb, settings.MaxBeaconAlarmDb)
+		}, nil},
+	{"Spectrum", func(s settings.Settings) string { return s.Url },
+		func(s *settings.Settings, dir int) { s.Url = cycle(settings.Urls(), s.Url, dir) }, nil},
+	{"Theme", func(s settings.Settings) string { return s.Theme },
+		func(s *settings.Settings, dir int) { s.Theme = cycle(themeNames(), s.Theme, dir) }, nil},
+}
+
+// Returns the value after value in list, or before it if dir is negative
+func cycle(list []string, value string, dir int) string {
+	i := slices.Index(list, value)
+	return list[(i+dir+len(list))%len(list)]
+}
+
+// the offsets of common LNBs, stepped through by the LNB offset < and > buttons. Others can be typed
+var kLnbOffsets = []float64{9750000, 10000000, 10600000, 10700000, 10750000}
+
+// Returns the next common LNB offset above offset, or below it if dir is negative
+func stepLnbOffset(offset float64, dir int) float64 {
+	if dir > 0 {
+		for _, lo := range kLnbOffsets {
+			if lo > offset {
+				return lo
+			}
+		}
+		return offset
+	}
+	for i := len(kLnbOffsets) - 1; i >= 0; i-- {
+		if kLnbOffsets[i] < offset {
+			return kLnbOffsets[i]
+		}
+	}
+	return offset
+}
+
+// Returns value plus step, rounded to 2 decimal places and limited to lo and hi
+func stepFloat(value, step, lo, hi float32) float32 {
+	v := float32(math.Round(float64(value+step)*100) / 100)
+	return min(max(v, lo), hi)
+}
+
+// Handles the settings buttons
+func (ui *UI) q100_SettingsClicks(gtx C) {
+	for i := range kSettingRows {
+		if ui.settingDec[i].Clicked(gtx) {
+			kSettingRows[i].step(&ui.edit, -1)
+		}
+		if ui.settingInc[i].Clicked(gtx) {
+			kSettingRows[i].step(&ui.edit, 1)
+		}
+		if ui.settingEntry[i].Clicked(gtx) && kSettingRows[i].typed != nil {
+			row := kSettingRows[i]
+			ui.openKeyboard(row.label, "", true, func(text string) error { return row.typed(&ui.edit, text) })
+		}
+	}
+	if ui.settingsUndo.Clicked(gtx) {
+		ui.edit = settings.Current()
+		ui.settingsMsg = ""
+	}
+	if ui.calibratePower.Clicked(gtx) {
+		title := fmt.Sprintf("Tune to a signal of known level and type it in dBm. The power offset is %.1f dB",
+			lmClient.PowerCalibration().OffsetDb)
+		ui.openKeyboard(title, "", true, func(text string) error {
+			dbm, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return errors.New("not a number of dBm")
+			}
+			if err := lmClient.CalibratePower(dbm); err != nil {
+				return err
+			}
+			ui.settingsMsg = fmt.Sprintf("Power calibrated to %v dBm", text)
+			return nil
+		})
+	}
+	if ui.settingsApply.Clicked(gtx) {
+		if err := settings.Apply(ui.edit); err != nil {
+			qLog.Warn("Settings: %v", err)
+			ui.settingsMsg = err.Error()
+		} else {
+			ui.settingsMsg = "Applied and saved, the start band is used from the next start"
+		}
+	}
+}
+
+// Returns the settings with < and > buttons to change them, the same size as the spectrum
+func (ui *UI) q100_Settings(gtx C) D {
+	const btnWidth = 50
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+	row := func(i int, lblWidth unit.Dp) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Dp(110)
+					return ui.q100_Label(gtx, kSettingRows[i].label, q100color.labelWhite)
+				}),
+				layout.Rigid(func(gtx C) D {
+					if kSettingRows[i].typed != nil {
+						return ui.q100_EntrySelector(gtx, &ui.settingDec[i], &ui.settingInc[i], &ui.settingEntry[i], kSettingRows[i].value(ui.edit), btnWidth, lblWidth)
+					}
+					return ui.q100_Selector(gtx, &ui.settingDec[i], &ui.settingInc[i], kSettingRows[i].value(ui.edit), btnWidth, lblWidth)
+				}),
+			)
+		})
+	}
+	edited := ui.edit != settings.Current()
+	// in 2 columns with the URL below and the theme beside the buttons, or a scrollable list in portrait
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{Spacing: layout.SpaceEvenly}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx, row(0, 100), row(1, 100), row(2, 100))
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx, row(3, 100), row(4, 100), row(5, 100))
+				}),
+			)
+		}),
+		row(6, 480),
+	}
+	if ui.portrait {
+		ui.settingsList.Axis = layout.Vertical
+		rows = []layout.FlexChild{
+			layout.Flexed(1, func(gtx C) D {
+				return material.List(ui.th, &ui.settingsList).Layout(gtx, len(kSettingRows), func(gtx C, i int) D {
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx, row(i, 240))
+				})
+			}),
+		}
+	}
+	buttons := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				return ui.q100_Button(gtx, &ui.settingsApply, "Apply", edited, q100color.buttonGreen)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				return ui.q100_Button(gtx, &ui.settingsUndo, "Undo", false, q100color.buttonGrey)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				return ui.q100_Button(gtx, &ui.calibratePower, "Power", false, q100color.buttonGrey)
+			})
+		}),
+	}
+	if !ui.portrait {
+		buttons = append(buttons, row(7, 100)) // the theme
+	}
+	buttons = append(buttons, layout.Flexed(1, func(gtx C) D {
+		return ui.q100_Label(gtx, ui.settingsMsg, q100color.labelWhite)
+	}))
+	buttonRow := layout.Rigid(func(gtx C) D {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx, buttons...)
+	})
+
+	return layout.Flex{
+		Axis:    layout.Horizontal,
+		Spacing: layout.SpaceSides,
+	}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			size := viewSize(gtx)
+			gtx.Constraints = layout.Exact(size)
+			paint.FillShape(gtx.Ops, q100color.gfxBgd, clip.Rect{Max: size}.Op())
+			return layout.Flex{
+				Axis: layout.Vertical,
+			}.Layout(gtx, append(rows, buttonRow)...)
+		}),
+	)
+}
+
+// Returns a message for the result of a favourites action
+func favouriteMessage(done string, err error) string {
+	if err != nil {
+		qLog.Warn("Favourites: %v", err)
+		return err.Error()
+	}
+	return done
+}
+
+// Returns the active and upcoming scheduled events, the same size as the spectrum
+func (ui *UI) q100_Schedule(gtx C) D {
+	const maxUpcoming = 20
+	occs := scheduler.Upcoming(maxUpcoming)
+	if active, ok := scheduler.Active(); ok {
+		occs = append([]scheduler.Occurrence{active}, occs...)
+	}
+	ui.schedule.Axis = layout.Vertical
+
+	return layout.Flex{
+		Axis:    layout.Horizontal,
+		Spacing: layout.SpaceSides,
+	}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			size := viewSize(gtx)
+			gtx.Constraints = layout.Exact(size)
+			paint.FillShape(gtx.Ops, q100color.gfxBgd, clip.Rect{Max: size}.Op())
+			if len(occs) == 0 {
+				return ui.q100_Label(gtx, "Nothing scheduled", q100color.labelWhite)
+			}
+			now := time.Now()
+			return material.List(ui.th, &ui.schedule).Layout(gtx, len(occs), func(gtx C, i int) D {
+				occ := occs[i]
+				what := "tune"
+				if occ.Record {
+					what = "record"
+				}
+				txtColor := q100color.labelOrange
+				if now.After(occ.Start) {
+					what = "now tuned"
+					if occ.Record {
+						what = "now recording"
+					}
+					txtColor = q100color.labelRed
+				}
+				return ui.q100_Label(gtx, fmt.Sprintf("%v UTC  %v min  %v  %vKS  %v",
+					occ.Start.Format("Mon 02 Jan 15:04"), occ.Duration.Minutes(), occ.Frequency, occ.SymbolRate, what), txtColor)
+			})
+		}),
+	)
+}
+
+// Returns a message for the result of a logbook export
+func exportMessage(path string, err error) string {
+	if err != nil {
+		qLog.Error("Failed to export the logbook: %v", err)
+		return "Export failed: " + err.Error()
+	}
+	return "Exported to " + path
+}
+
+// Returns a graph of the MER, margin and power history
+func (ui *UI) q100_SignalGraph(gtx C) D {
+	const dbMin, dbMax = -5, 20      // MER and margin
+	const dbmMin, dbmMax = -100, -30 // power
+	const left, right, bottom, top = 6, 94, 5, 88
+
+	return layout.Flex{
+		Axis:    layout.Horizontal,
+		Spacing: layout.SpaceSides,
+	}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			size := viewSize(gtx)
+			canvas := giocanvas.Canvas{
+				Width:   float32(size.X),
+				Height:  float32(size.Y),
+				Context: gtx,
+				Theme:   ui.th,
+			}
+			canvas.Background(q100color.gfxBgd)
+
+			yOf := func(v, vMin, vMax float64) float32 {
+				return bottom + float32((v-vMin)/(vMax-vMin))*(top-bottom)
+			}
+			// graticule, dB on the left and dBm on the right
+			for db := dbMin; db <= dbMax; db += 5 {
+				y := yOf(float64(db), dbMin, dbMax)
+				canvas.HLine(left, y, right-left, 0.005, q100color.gfxGraticule)
+				canvas.TextEnd(left-0.5, y, 1.5, fmt.Sprintf("%vdB", db), q100color.gfxLabel)
+				dbm := dbmMin + float64(db-dbMin)*(dbmMax-dbmMin)/(dbMax-dbMin)
+				canvas.Text(right+0.5, y, 1.5, fmt.Sprintf("%.0f", dbm), q100color.gfxLabel)
+			}
+
+			samples, capacity := lmClient.SignalHistory()
+			if capacity > 1 {
+				step := (right - left) / float32(capacity-1)
+				x0 := right - step*float32(len(samples)-1)
+				trace := func(value func(s lmClient.SignalSample) float64, vMin, vMax float64, c color.NRGBA) {
+					for i := 1; i < len(samples); i++ {
+						v0, v1 := value(samples[i-1]), value(samples[i])
+						if math.IsNaN(v0) || math.IsNaN(v1) {
+							continue
+						}
+						v0 = min(max(v0, vMin), vMax)
+						v1 = min(max(v1, vMin), vMax)
+						x := x0 + step*float32(i)
+						canvas.Line(x-step, yOf(v0, vMin, vMax), x, yOf(v1, vMin, vMax), 0.2, c)
+					}
+				}
+				trace(func(s lmClient.SignalSample) float64 { return s.Power }, dbmMin, dbmMax, q100color.gfxPower)
+				trace(func(s lmClient.SignalSample) float64 { return s.Margin }, dbMin, dbMax, q100color.labelOrange)
+				trace(func(s lmClient.SignalSample) float64 { return s.Mer }, dbMin, dbMax, q100color.gfxGreen)
+				if len(samples) > 0 {
+					span := samples[len(samples)-1].Time.Sub(samples[0].Time).Round(time.Second)
+					canvas.TextEnd(right, 93, 1.5, fmt.Sprintf("last %v", span), q100color.gfxGraticule)
+				}
+			}
+			// legend
+			canvas.Text(left, 93, 1.5, "MER "+lmData.DbMer, q100color.gfxGreen)
+			canvas.Text(left+20, 93, 1.5, "Margin "+lmData.DbMargin, q100color.labelOrange)
+			canvas.Text(left+40, 93, 1.5, "Power "+lmData.DbmPower+" dBm", q100color.gfxPower)
+
+			return layout.Dimensions{
+				Size: image.Point{X: int(canvas.Width), Y: int(canvas.Height)},
+			}
+		}),
+	)
+}
+
+// Updates the alignment meter from the latest MER, power and beacon SNR
+func (ui *UI) q100_UpdateMeter() {
+	signal := lmClient.LatestSignal()
+	mer := signal.Mer
+	if !rxState.IsLocked {
+		mer = math.NaN()
+	}
+	ui.meter = alignment.Update(mer, signal.Power, float64(spData.BeaconSnr))
+}
+
+// Returns 1 row with a label and buttons for Tone, Reset Peaks and Exit
+func (ui *UI) q100_AlignmentRow(gtx C) D {
+	const btnWidth = 30
+	inset := layout.Inset{
+		Top:    2,
+		Bottom: 2,
+		Left:   4,
+		Right:  4,
+	}
+
+	return layout.Flex{
+		Alignment: layout.Middle,
+	}.Layout(gtx,
+		layout.Flexed(1, func(gtx C) D {
+			return ui.q100_Label(gtx, "Dish Alignment on the beacon   "+lmData.StatusMsg, q100color.labelOrange)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.alignTone, "Tone", alignment.ToneEnabled(), q100color.buttonGreen)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.alignReset, "Reset Peaks", false, q100color.buttonGrey)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.alignExit, "Exit", false, q100color.buttonGrey)
+			})
+		}),
+	)
+}
+
+// Returns the full screen dish alignment display
+//
+//	a bar for each of MER, power and beacon SNR, with a peak-hold marker
+func (ui *UI) q100_AlignmentDisplay(gtx C) D {
+	return layout.Flex{
+		Axis: layout.Vertical,
+	}.Layout(gtx,
+		layout.Rigid(ui.q100_AlignmentRow),
+		layout.Flexed(1, func(gtx C) D {
+			canvas := giocanvas.Canvas{
+				Width:   float32(gtx.Constraints.Max.X),
+				Height:  float32(gtx.Constraints.Max.Y),
+				Context: gtx,
+				Theme:   ui.th,
+			}
+			canvas.Background(q100color.gfxBgd)
+
+			bar := func(y float32, name, format string, r alignment.Reading, c color.NRGBA) {
+				const left, width, height = 22, 56, 16
+				canvas.TextEnd(left-2, y-2, 4, name, q100color.gfxGraticule)
+				canvas.Rect(left+width/2, y, width, height, q100color.gfxMarker)
+				if math.IsNaN(r.Value) {
+					canvas.Text(left+width+2, y-2, 5, "-", c)
+				} else {
+					level := width * float32(r.Level)
+					canvas.Rect(left+level/2, y, level, height, c)
+					canvas.Text(left+width+2, y-2, 5, fmt.Sprintf(format, r.Value), c)
+				}
+				if !math.IsNaN(r.Peak) {
+					canvas.VLine(left+width*float32(r.PeakLevel), y-height/2, height, 0.6, q100color.gfxHeld)
+					canvas.Text(left+width+2, y-height/2, 2.5, "peak "+fmt.Sprintf(format, r.Peak), q100color.gfxHeld)
+				}
+			}
+			bar(78, "MER", "%.1f dB", ui.meter.Mer, q100color.gfxGreen)
+			bar(50, "Power", "%.0f dBm", ui.meter.Power, q100color.gfxPower)
+			bar(22, "Beacon", "%.1f dB", ui.meter.Beacon, q100color.gfxBeacon)
+			canvas.TextMid(50, 3, 3, fmt.Sprintf("Quality %.0f%%", ui.meter.Quality*100), q100color.gfxGraticule)
+
+			return layout.Dimensions{
+				Size: image.Point{X: int(canvas.Width), Y: int(canvas.Height)},
+			}
+		}),
 	)
 }
 
 // returns [ label__  label__ ]
-func (ui *UI) q100_LabelValue(gtx C, label, value string) D {
+func (ui *UI) q100_LabelValue(gtx C, label, value string, valueColor color.NRGBA) D {
 	const lblWidth = 105
 	const valWidth = 110
 	inset := layout.Inset{
@@ -473,34 +2264,47 @@ func (ui *UI) q100_LabelValue(gtx C, label, value string) D {
 			return inset.Layout(gtx, func(gtx C) D {
 				gtx.Constraints.Min.X = gtx.Dp(valWidth)
 				gtx.Constraints.Max.X = gtx.Dp(valWidth)
-				return ui.q100_Label(gtx, value, q100color.labelOrange)
+				return ui.q100_Label(gtx, value, valueColor)
 			})
 		}),
 	)
 }
 
 // returns a column of 4 rows of [label__  label__]
-func (ui *UI) q100_Column4Rows(gtx C, name, value [4]string) D {
+func (ui *UI) q100_Column4Rows(gtx C, name, value [4]string, valueColor [4]color.NRGBA) D {
 	return layout.Flex{
 		Axis: layout.Vertical,
 		// Spacing: layout.SpaceEvenly,
 	}.Layout(gtx,
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_LabelValue(gtx, name[0], value[0])
+			return ui.q100_LabelValue(gtx, name[0], value[0], valueColor[0])
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_LabelValue(gtx, name[1], value[1])
+			return ui.q100_LabelValue(gtx, name[1], value[1], valueColor[1])
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_LabelValue(gtx, name[2], value[2])
+			return ui.q100_LabelValue(gtx, name[2], value[2], valueColor[2])
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_LabelValue(gtx, name[3], value[3])
+			return ui.q100_LabelValue(gtx, name[3], value[3], valueColor[3])
 		}),
 	)
 }
 
-// returns a column with 2 buttons
+// Returns the color of the margin for its state
+func (ui *UI) q100_MarginColor() color.NRGBA {
+	switch lmData.MarginState {
+	case lmClient.MarginFailing:
+		return q100color.labelRed
+	case lmClient.MarginMarginal:
+		return q100color.labelYellow
+	case lmClient.MarginSolid:
+		return q100color.labelGreen
+	}
+	return q100color.labelOrange
+}
+
+// returns a column with 2 buttons, or a row in portrait
 func (ui *UI) q100_Column2Buttons(gtx C) D {
 	const btnWidth = 70
 	const btnHeight = 50
@@ -510,50 +2314,101 @@ func (ui *UI) q100_Column2Buttons(gtx C) D {
 		Left:   4,
 		Right:  4,
 	}
+	axis := layout.Vertical
+	if ui.portrait {
+		axis = layout.Horizontal
+	}
 	return layout.Flex{
-		Axis:    layout.Vertical,
+		Axis:    axis,
 		Spacing: layout.SpaceEvenly,
 	}.Layout(gtx,
 		layout.Rigid(func(gtx C) D {
 			return inset.Layout(gtx, func(gtx C) D {
 				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
 				gtx.Constraints.Min.Y = gtx.Dp(btnHeight)
-				return ui.q100_Button(gtx, &ui.tune, "TUNE", rxControl.IsTuned, q100color.buttonGreen)
+				return ui.q100_Button(gtx, &ui.tune, "TUNE", rxState.IsTuned, q100color.buttonGreen)
 			})
 		}),
 		layout.Rigid(func(gtx C) D {
 			return inset.Layout(gtx, func(gtx C) D {
 				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
 				gtx.Constraints.Min.Y = gtx.Dp(btnHeight)
-				return ui.q100_Button(gtx, &ui.stream, "STREAM", rxControl.IsStreaming, q100color.buttonRed)
+				return ui.q100_Button(gtx, &ui.stream, "STREAM", rxState.IsStreaming, q100color.buttonRed)
 			})
 		}),
 	)
 }
 
 // Returns a 3x4 matrix of status + 1 column with 2 buttons
+//
+//	touch the matrix to cycle between the spectrum, signal graph, stream list, logbook and schedule.
+//	In portrait the third column is below the first two, and the buttons are below the matrix
 func (ui *UI) q100_3x4statusMatrixPlus2buttons(gtx C) D {
 	names1 := [4]string{"Frequency", "Symbol Rate", "Mode", "Constellation"}
-	values1 := [4]string{lmData.Frequency, lmData.SymbolRate, lmData.Mode, lmData.Constellation}
+	freq := lmData.Frequency
+	if rxState.HasDeviation {
+		freq += fmt.Sprintf(" %+.0fk", rxState.Deviation)
+	}
+	values1 := [4]string{freq, lmData.SymbolRate, lmData.Mode, lmData.Constellation}
 	names2 := [4]string{"FEC", "Codecs", "dB MER", "dB Margin"}
 	values2 := [4]string{lmData.Fec, lmData.VideoCodec + " " + lmData.AudioCodec, lmData.DbMer, lmData.DbMargin}
+	orange := q100color.labelOrange
+	colors := [4]color.NRGBA{orange, orange, orange, orange}
+	colors2 := [4]color.NRGBA{orange, orange, orange, ui.q100_MarginColor()}
 	// names3 := [4]string{"dBm Power", "Null Ratio", "Provider", "Service"}
 	// values3 := [4]string{lmData.DbmPower, lmData.NullRatio, lmData.Provider, lmData.Service}
-	names3 := [4]string{"dBm Power", "Null Ratio %", "Video PID", "Audio PID"}
-	values3 := [4]string{lmData.DbmPower, lmData.NullRatio, lmData.PidPair1, lmData.PidPair2}
+	lnb := lmData.Lnb
+	if lmData.LnbMismatch {
+		lnb += " !" // the requested supply is shown on the LNB button
+	}
+	names3 := [4]string{"dBm Power", "Null Ratio %", "PIDs", "LNB"}
+	pids := lmData.PidPair1 + ", " + lmData.PidPair2
+	if lmData.EsCount > 2 {
+		pids += fmt.Sprintf(" +%v", lmData.EsCount-2) // touch the matrix for the full list
+	}
+	values3 := [4]string{lmData.DbmPower, lmData.NullRatio, pids, lnb}
+
+	column1 := layout.Rigid(func(gtx C) D {
+		return ui.q100_Column4Rows(gtx, names1, values1, colors)
+	})
+	column2 := layout.Rigid(func(gtx C) D {
+		return ui.q100_Column4Rows(gtx, names2, values2, colors2)
+	})
+	column3 := layout.Rigid(func(gtx C) D {
+		return ui.q100_Column4Rows(gtx, names3, values3, colors)
+	})
 
+	if ui.portrait {
+		return layout.Flex{
+			Axis: layout.Vertical,
+		}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return ui.matrix.Layout(gtx, func(gtx C) D {
+					return layout.Flex{
+						Axis: layout.Vertical,
+					}.Layout(gtx,
+						layout.Rigid(func(gtx C) D {
+							return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, column1, column2)
+						}),
+						column3,
+					)
+				})
+			}),
+			layout.Rigid(func(gtx C) D {
+				return ui.q100_Column2Buttons(gtx)
+			}),
+		)
+	}
 	return layout.Flex{
 		Axis: layout.Horizontal,
 		// Spacing: layout.SpaceEvenly,
 	}.Layout(gtx,
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_Column4Rows(gtx, names1, values1)
-		}),
-		layout.Rigid(func(gtx C) D {
-			return ui.q100_Column4Rows(gtx, names2, values2)
-		}),
-		layout.Rigid(func(gtx C) D {
-			return ui.q100_Column4Rows(gtx, names3, values3)
+			return ui.matrix.Layout(gtx, func(gtx C) D {
+				return layout.Flex{
+					Axis: layout.Horizontal,
+				}.Layout(gtx, column1, column2, column3)
+			})
 		}),
 		layout.Rigid(func(gtx C) D {
 			return ui.q100_Column2Buttons(gtx)
@@ -563,6 +2418,36 @@ func (ui *UI) q100_3x4statusMatrixPlus2buttons(gtx C) D {
 
 // layoutFlexes returns the entire display
 func (ui *UI) layoutFlexes(gtx C) D {
+	if rxState.Aligning {
+		return ui.q100_AlignmentDisplay(gtx)
+	}
+	var dialog layout.Widget
+	switch {
+	case ui.kb != nil:
+		dialog = ui.q100_Keyboard
+	case ui.shutdownOpen:
+		dialog = ui.q100_ShutdownDialog
+	case ui.aboutOpen:
+		dialog = ui.q100_AboutBox
+	default:
+		return ui.layoutMain(gtx)
+	}
+	return layout.Stack{Alignment: layout.Center}.Layout(gtx,
+		layout.Stacked(ui.layoutMain),
+		layout.Expanded(func(gtx C) D {
+			// touch outside the dialog to close it
+			return ui.dismiss.Layout(gtx, func(gtx C) D {
+				size := gtx.Constraints.Min
+				paint.FillShape(gtx.Ops, q100color.overlayGrey, clip.Rect{Max: size}.Op())
+				return D{Size: size}
+			})
+		}),
+		layout.Stacked(dialog),
+	)
+}
+
+// Returns the top row, the main view, the tuning row and the status matrix
+func (ui *UI) layoutMain(gtx C) D {
 	return layout.Flex{
 		Axis: layout.Vertical,
 	}.Layout(gtx,
@@ -572,8 +2457,12 @@ func (ui *UI) layoutFlexes(gtx C) D {
 				// Spacing:   layout.SpaceEnd,
 				// Alignment: layout.Alignment(layout.N),
 			}.Layout(gtx,
-				layout.Rigid(ui.q100_TopStatusRow),
-				layout.Rigid(ui.q100_SpectrumDisplay),
+				layout.Rigid(func(gtx C) D {
+					dims := ui.q100_TopStatusRow(gtx)
+					ui.topRowHeight = dims.Size.Y
+					return dims
+				}),
+				layout.Flexed(1, ui.q100_MainView),
 				layout.Rigid(ui.q100_MainTuningRow),
 				layout.Rigid(ui.q100_3x4statusMatrixPlus2buttons),
 			)
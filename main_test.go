@@ -0,0 +1,32 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import "testing"
+
+func TestStepLnbOffset(t *testing.T) {
+	tests := []struct {
+		offset float64
+		dir    int
+		want   float64
+	}{
+		{0, 1, 9750000},
+		{9750000, 1, 10000000},
+		{9750001, 1, 10000000},
+		{10700000, 1, 10750000},
+		{10750000, 1, 10750000}, // the highest stays
+		{11000000, 1, 11000000},
+		{10750000, -1, 10700000},
+		{10599999, -1, 10000000},
+		{9750000, -1, 9750000}, // the lowest stays
+		{0, -1, 0},
+	}
+	for _, tt := range tests {
+		if got := stepLnbOffset(tt.offset, tt.dir); got != tt.want {
+			t.Errorf("stepLnbOffset(%.0f, %v) = %.0f, want %.0f", tt.offset, tt.dir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import (
+	"math"
+	"q100receiver-bookworm/lmClient"
+	"q100receiver-bookworm/spectrumClient"
+	"strconv"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+// Turns automatic frequency control on or off
+func ToggleAfc() {
+	mu.Lock()
+	defer mu.Unlock()
+	afc = !afc
+	qLog.Info("AFC on: %v", afc)
+	publish(EventAfcChanged)
+}
+
+// END API ****************************************************
+
+const (
+	kAfcInterval     = time.Second
+	kAfcHoldoff      = 5 * time.Second // after retuning, for longmynd to lock again
+	kAfcSamples      = 3               // consecutive deviations before retuning
+	kAfcFraction     = 0.25            // of the symbol rate, the deviation that retunes
+	kAfcMinThreshold = 5.0             // KHz
+)
+
+// guarded by mu
+var (
+	afc          bool
+	deviationKHz float64 // of the locked carrier from the tuned frequency
+	hasDeviation bool    // deviationKHz is valid
+)
+
+// Measures the deviation of the locked carrier forever, and retunes to centre it when AFC is on
+func runAfc() {
+	var count int
+	var holdoff time.Time
+	var atLimit bool // logged once, until AFC can retune again
+	for now := range time.Tick(kAfcInterval) {
+		carrierKHz, locked := lmClient.CarrierKHz()
+		mu.Lock()
+		wasDeviation, wasKHz := hasDeviation, math.Round(deviationKHz)
+		hasDeviation = false
+		mhz, err := spectrumClient.ParseFrequency(tuneFrequency())
+		if isTuned && locked && err == nil {
+			deviationKHz = carrierKHz + lmClient.Offset() - mhz*1000
+			hasDeviation = true
+		}
+		if hasDeviation != wasDeviation || (hasDeviation && math.Round(deviationKHz) != wasKHz) {
+			publish(EventDeviationChanged)
+		}
+		if !hasDeviation {
+			count = 0
+			mu.Unlock()
+			continue
+		}
+		kS, _ := strconv.ParseFloat(symbolRate.value, 64)
+		threshold := max(kS*kAfcFraction, kAfcMinThreshold)
+		if !afc || busy() || now.Before(holdoff) || math.Abs(deviationKHz) <= threshold {
+			count = 0
+			mu.Unlock()
+			continue
+		}
+		if count++; count >= kAfcSamples {
+			count = 0
+			fine := min(max(fineKHz+int(math.Round(deviationKHz)), -kMaxFineKHz), kMaxFineKHz)
+			if fine == fineKHz {
+				if !atLimit {
+					qLog.Warn("AFC: cannot retune by %.0f KHz, fine tuning is at its limit of %v KHz", deviationKHz, fineKHz)
+				}
+				atLimit = true
+			} else {
+				qLog.Info("AFC: retuning by %v KHz", fine-fineKHz)
+				fineKHz = fine
+				retune()
+				atLimit = false
+			}
+			holdoff = now.Add(kAfcHoldoff)
+		}
+		mu.Unlock()
+	}
+}
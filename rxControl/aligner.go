@@ -0,0 +1,55 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import (
+	"q100receiver-bookworm/lmClient"
+)
+
+// BEGIN API ****************************************************
+
+// Tunes to the beacon for dish alignment
+//
+//	the previous band is restored, untuned, by StopAlignment
+func StartAlignment() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	aligning = true
+	alignPreviousBand = band.value
+	band = newSelector(const_BAND_LIST, const_BAND_LIST[0])
+	switchBand()
+	tune()
+	publish(EventAlignmentStarted)
+}
+
+// Untunes from the beacon and restores the previous band
+func StopAlignment() {
+	mu.Lock()
+	defer mu.Unlock()
+	if !aligning {
+		return
+	}
+	if isTuned {
+		lmClient.UnTune()
+		isTuned = false
+		publish(EventUnTuned)
+	}
+	band = newSelector(const_BAND_LIST, alignPreviousBand)
+	switchBand()
+	aligning = false
+	publish(EventAlignmentFinished)
+}
+
+// END API ****************************************************
+
+// guarded by mu
+var (
+	aligning          bool
+	alignPreviousBand string
+)
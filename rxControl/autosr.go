@@ -0,0 +1,125 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import (
+	"math"
+	"q100receiver-bookworm/lmClient"
+	"q100receiver-bookworm/spectrumClient"
+	"strconv"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+// Tunes with every symbol rate of the band, and selects the one longmynd locks to
+//
+//	detection continues in the background until longmynd locks, or the selection
+//	changes, or the receiver is untuned
+func AutoSymbolRate() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if isTuned {
+		lmClient.UnTune()
+		isTuned = false
+		publish(EventUnTuned)
+	}
+	opts := bandOptions()
+	opts.SymbolRates = symbolRate.list
+	lmClient.Tune(tuneFrequency(), symbolRate.value, opts)
+	isTuned = lmClient.IsTuned()
+	if !isTuned {
+		return
+	}
+	detecting = true
+	publish(EventTuned)
+	publish(EventDetectionStarted)
+	go runDetection(selectionGen)
+}
+
+// END API ****************************************************
+
+const (
+	kDetectionInterval = 250 * time.Millisecond
+	kDetectionSettle   = 2    // matching locked readings, so ID 9 is from the locked signal
+	kDetectionMaxError = 0.05 // of the nearest symbol rate in the list
+)
+
+// guarded by mu
+var (
+	detecting    bool
+	selectionGen int // incremented whenever the selection changes
+)
+
+// Polls the symbol rate reported by longmynd until it is locked and settled
+func runDetection(gen int) {
+	qLog.Info("Symbol rate detection has started")
+	var last float64
+	var settled int
+	for {
+		time.Sleep(kDetectionInterval)
+		mu.Lock()
+		if gen != selectionGen || !isTuned {
+			qLog.Info("Symbol rate detection abandoned")
+			detecting = false
+			publish(EventDetectionFinished)
+			mu.Unlock()
+			return
+		}
+		mu.Unlock()
+
+		kS, locked := lmClient.SymbolRateKS()
+		if !locked || kS <= 0 {
+			settled = 0
+			continue
+		}
+		if kS == last {
+			settled++
+		} else {
+			last = kS
+			settled = 1
+		}
+		if settled >= kDetectionSettle {
+			break
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	detecting = false
+	if gen == selectionGen {
+		if sr, ok := nearestSymbolRate(symbolRate.list, last); ok {
+			qLog.Info("Symbol rate detected: %v KS, reported as %.1f KS", sr, last)
+			// still tuned, so the selection changes without somethingChanged
+			symbolRate = newSelector(symbolRate.list, sr)
+			spectrumClient.SetMarker(tuneFrequency(), symbolRate.value)
+			publish(EventSelectionChanged)
+		} else {
+			qLog.Warn("Symbol rate detected: %.1f KS is not in the list", last)
+		}
+	}
+	publish(EventDetectionFinished)
+}
+
+// Returns the symbol rate in the list nearest to kS, and false if none are close
+func nearestSymbolRate(list []string, kS float64) (string, bool) {
+	best, bestErr := "", math.Inf(1)
+	for _, sr := range list {
+		v, err := strconv.ParseFloat(sr, 64)
+		if err != nil {
+			continue
+		}
+		if e := math.Abs(kS-v) / v; e < bestErr {
+			best, bestErr = sr, e
+		}
+	}
+	return best, bestErr <= kDetectionMaxError
+}
@@ -0,0 +1,110 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import (
+	"math"
+	"q100receiver-bookworm/lmClient"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+// Calibrates the LNB offset using the QO-100 beacon
+//
+//	tunes to the beacon, waits for lock, averages the carrier frequency reported
+//	by longmynd and saves the difference as the new LNB offset. Afterwards the
+//	previous band is restored, untuned. Runs in the background, and the
+//	selection cannot be changed until it has finished.
+func Calibrate() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	calibrating = true
+	previousBand := band.value
+	band = newSelector(const_BAND_LIST, const_BAND_LIST[0])
+	switchBand()
+	lmClient.Tune(tuneFrequency(), symbolRate.value, bandOptions())
+	isTuned = lmClient.IsTuned()
+	publish(EventCalibrationStarted)
+	if !isTuned {
+		finishCalibration(previousBand)
+		return
+	}
+	go runCalibration(previousBand, selectionGen)
+}
+
+// END API ****************************************************
+
+const (
+	kBeaconKHz              = 10491500.0
+	kCalibrationTimeout     = 30 * time.Second
+	kCalibrationInterval    = 250 * time.Millisecond
+	kCalibrationSamples     = 20
+	kMaxOffsetCorrectionKHz = 2000.0 // far more than any LNB drifts
+)
+
+// guarded by mu
+var calibrating bool
+
+// Collects carrier frequencies until enough have been received, or the timeout expires
+//
+//	abandoned if the selection changes from gen, so only the beacon is averaged
+func runCalibration(previousBand string, gen int) {
+	qLog.Info("Calibration has started")
+	var sum float64
+	var samples int
+	deadline := time.Now().Add(kCalibrationTimeout)
+	for samples < kCalibrationSamples && time.Now().Before(deadline) {
+		time.Sleep(kCalibrationInterval)
+		mu.Lock()
+		tuned := isTuned && gen == selectionGen
+		mu.Unlock()
+		if !tuned {
+			qLog.Warn("Calibration abandoned: no longer tuned to the beacon")
+			break
+		}
+		if kHz, locked := lmClient.CarrierKHz(); locked {
+			sum += kHz
+			samples++
+		}
+	}
+
+	if samples == kCalibrationSamples {
+		offset := kBeaconKHz - sum/float64(samples)
+		correction := offset - lmClient.Offset()
+		if math.Abs(correction) > kMaxOffsetCorrectionKHz {
+			qLog.Warn("Calibration rejected: the offset would change by %.0f KHz", correction)
+		} else if err := lmClient.SetOffset(offset); err != nil {
+			qLog.Error("Failed to save the LNB offset: %v", err)
+		} else {
+			qLog.Info("Calibration has finished: LNB offset %.0f KHz, changed by %.0f KHz", offset, correction)
+		}
+	} else {
+		qLog.Warn("Calibration failed: only %v of %v samples from the beacon", samples, kCalibrationSamples)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	finishCalibration(previousBand)
+}
+
+// Untunes and restores the previous band. Must be called with mu held
+func finishCalibration(previousBand string) {
+	if isTuned {
+		lmClient.UnTune()
+		isTuned = false
+		publish(EventUnTuned)
+	}
+	band = newSelector(const_BAND_LIST, previousBand)
+	switchBand()
+	calibrating = false
+	publish(EventCalibrationFinished)
+}
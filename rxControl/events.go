@@ -0,0 +1,86 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import (
+	"sync"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+type EventKind int
+
+const (
+	EventTuned EventKind = iota
+	EventUnTuned
+	EventBandChanged
+	EventSelectionChanged // symbol rate or frequency
+	EventStreamChanged
+	EventLnbChanged
+	EventCalibrationStarted
+	EventCalibrationFinished
+	EventLockChanged
+	EventAlignmentStarted
+	EventAlignmentFinished
+	EventFavouritesChanged
+	EventDetectionStarted
+	EventDetectionFinished
+	EventAfcChanged
+	EventDeviationChanged // of the locked carrier, rounded to 1 KHz
+)
+
+// Sent to subscribers whenever the receiver state changes
+type Event struct {
+	Kind  EventKind
+	State State
+}
+
+// Returns a channel of receiver events and a function to cancel the subscription
+//
+//	events are dropped, with a warning, if the subscriber falls behind
+func Subscribe() (<-chan Event, func()) {
+	subMu.Lock()
+	defer subMu.Unlock()
+	id := nextSubscriber
+	nextSubscriber++
+	ch := make(chan Event, kEventBufferSize)
+	subscribers[id] = ch
+	cancel := func() {
+		subMu.Lock()
+		defer subMu.Unlock()
+		if ch, ok := subscribers[id]; ok {
+			delete(subscribers, id)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// END API ****************************************************
+
+const kEventBufferSize = 16
+
+var (
+	subMu          sync.Mutex
+	subscribers    = make(map[int]chan Event)
+	nextSubscriber int
+)
+
+// Sends an event to all subscribers without blocking. Must be called with mu held
+func publish(kind EventKind) {
+	ev := Event{Kind: kind, State: currentState()}
+	subMu.Lock()
+	defer subMu.Unlock()
+	for _, ch := range subscribers {
+		select {
+		case ch <- ev:
+		default:
+			qLog.Warn("rxControl event %v dropped", kind)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"q100receiver-bookworm/lmClient"
+	"slices"
+	"strings"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+// A memory channel
+type Favourite struct {
+	Name       string              `json:"name"`
+	Band       string              `json:"band"`
+	Frequency  string              `json:"frequency"`         // eg. "10499.25 / 27"
+	SymbolRate string              `json:"symbol_rate"`       // eg. "333"
+	Options    *lmClient.LmOptions `json:"options,omitempty"` // nil for the band's options
+}
+
+// Returns a copy of the favourites
+func Favourites() []Favourite {
+	mu.Lock()
+	defer mu.Unlock()
+	return slices.Clone(favourites)
+}
+
+// Selects a favourite and tunes to it
+func Recall(index int) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return errors.New("busy")
+	}
+	if index < 0 || index >= len(favourites) {
+		return fmt.Errorf("no favourite %v", index)
+	}
+	fav := favourites[index]
+	if !slices.Contains(const_BAND_LIST, fav.Band) {
+		return fmt.Errorf("favourite %q has an unknown band %q", fav.Name, fav.Band)
+	}
+	symbolRates, frequencies := bandLists(fav.Band)
+	if !slices.Contains(frequencies, fav.Frequency) || !slices.Contains(symbolRates, fav.SymbolRate) {
+		freq, err := validateManual(fav.Frequency, fav.SymbolRate)
+		if err != nil {
+			return fmt.Errorf("favourite %q: %v", fav.Name, err)
+		}
+		fav.Frequency = freq
+	}
+	qLog.Info("Recalled favourite %q", fav.Name)
+	selectAndTune(fav.Band, fav.Frequency, fav.SymbolRate, fav.Options)
+	return nil
+}
+
+// Saves the current band, frequency and symbol rate as a new favourite,
+// named after them if name is empty
+func SaveFavourite(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	name = strings.TrimSpace(name)
+	if name == "" {
+		_, channel, _ := strings.Cut(tuneFrequency(), "/")
+		name = fmt.Sprintf("Ch %v %vKS", strings.TrimSpace(channel), symbolRate.value)
+	}
+	fav := Favourite{
+		Name:       name,
+		Band:       band.value,
+		Frequency:  tuneFrequency(),
+		SymbolRate: symbolRate.value,
+		Options:    favOptions,
+	}
+	if slices.ContainsFunc(favourites, func(f Favourite) bool {
+		return f.Band == fav.Band && f.Frequency == fav.Frequency && f.SymbolRate == fav.SymbolRate
+	}) {
+		return fmt.Errorf("%v is already a favourite", fav.Name)
+	}
+	favourites = append(favourites, fav)
+	publish(EventFavouritesChanged)
+	return saveFavourites()
+}
+
+// Renames a favourite
+func RenameFavourite(index int, name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if index < 0 || index >= len(favourites) {
+		return fmt.Errorf("no favourite %v", index)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("the name is empty")
+	}
+	qLog.Info("Favourite %q renamed %q", favourites[index].Name, name)
+	favourites[index].Name = name
+	publish(EventFavouritesChanged)
+	return saveFavourites()
+}
+
+// Deletes a favourite
+func DeleteFavourite(index int) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if index < 0 || index >= len(favourites) {
+		return fmt.Errorf("no favourite %v", index)
+	}
+	favourites = slices.Delete(favourites, index, index+1)
+	publish(EventFavouritesChanged)
+	return saveFavourites()
+}
+
+// END API ****************************************************
+
+// guarded by mu
+var (
+	favourites []Favourite
+	favOptions *lmClient.LmOptions // from the recalled favourite, until the selection changes
+)
+
+// Selects a band, frequency and symbol rate, with options or nil for the band's options,
+// then tunes. Must be called with mu held, with values from the band's lists or validated by validateManual
+func selectAndTune(bandName, freq, sr string, opts *lmClient.LmOptions) {
+	band = newSelector(const_BAND_LIST, bandName)
+	switchBand()
+	if slices.Contains(frequency.list, freq) && slices.Contains(symbolRate.list, sr) {
+		frequency = newSelector(frequency.list, freq)
+		symbolRate = newSelector(symbolRate.list, sr)
+		somethingChanged(EventSelectionChanged)
+	} else {
+		selectManual(freq, sr)
+	}
+	favOptions = opts
+	tune()
+}
+
+// Reads TuConfig.FavouritesFile. Must be called with mu held
+func loadFavourites() {
+	if tuCfg.FavouritesFile == "" {
+		return
+	}
+	data, err := os.ReadFile(tuCfg.FavouritesFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			qLog.Warn("Failed to read favourites: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &favourites); err != nil {
+		qLog.Warn("Bad favourites in %v: %v", tuCfg.FavouritesFile, err)
+		favourites = nil
+		return
+	}
+	qLog.Info("%v favourites loaded from %v", len(favourites), tuCfg.FavouritesFile)
+}
+
+// Writes TuConfig.FavouritesFile. Must be called with mu held
+func saveFavourites() error {
+	if tuCfg.FavouritesFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(favourites, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tuCfg.FavouritesFile, append(data, '\n'), 0644)
+}
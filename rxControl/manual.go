@@ -0,0 +1,143 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import (
+	"errors"
+	"fmt"
+	"q100receiver-bookworm/lmClient"
+	"q100receiver-bookworm/spectrumClient"
+	"strconv"
+	"strings"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+// Tunes to any frequency in MHz, eg. "10497.83", and symbol rate in KS, eg. "333"
+//
+//	the frequency must be within range of the tuner after the LNB offset. The
+//	frequency and symbol rate selectors hold only these values until the band changes
+func TuneManual(frequencyMHz, sr string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return errors.New("busy")
+	}
+	freq, err := validateManual(frequencyMHz, sr)
+	if err != nil {
+		return err
+	}
+	selectManual(freq, sr)
+	tune()
+	return nil
+}
+
+// Returns an error if TuneManual would refuse the frequency in MHz or the symbol rate in KS
+func ValidateManual(frequencyMHz, sr string) error {
+	_, err := validateManual(frequencyMHz, sr)
+	return err
+}
+
+// Moves the tuned frequency by deltaKHz, eg. 25 or -25, and retunes if tuned
+//
+//	the fine tuning is cleared when the selection changes
+func FineTune(deltaKHz int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	fine := min(max(fineKHz+deltaKHz, -kMaxFineKHz), kMaxFineKHz)
+	if fine == fineKHz {
+		return
+	}
+	fineKHz = fine
+	retune()
+}
+
+// END API ****************************************************
+
+const (
+	kMaxFineKHz     = 500
+	kMinTunerKHz    = 144000  // MiniTiouner NIM input range
+	kMaxTunerKHz    = 2450000 //
+	kMinSymbolRate  = 33      // KS
+	kMaxSymbolRate  = 27500   //
+	kUnknownChannel = "--"
+)
+
+// guarded by mu
+var fineKHz int
+
+// Returns the frequency to tune to, including any fine tuning. Must be called with mu held
+func tuneFrequency() string {
+	if fineKHz == 0 {
+		return frequency.value
+	}
+	mhz, err := spectrumClient.ParseFrequency(frequency.value)
+	if err != nil {
+		return frequency.value
+	}
+	return manualFrequency(mhz + float64(fineKHz)/1000)
+}
+
+// Returns a frequency in the style of the lists, eg. "10497.830 / 21", with the nearest channel
+func manualFrequency(mhz float64) string {
+	channel := kUnknownChannel
+	if ch := spectrumClient.FrequencyChannel(mhz); ch >= 0 {
+		channel = fmt.Sprintf("%02d", ch)
+	}
+	return fmt.Sprintf("%.3f / %v", mhz, channel)
+}
+
+// Returns the frequency in the style of the lists, or an error if it can not be tuned
+func validateManual(frequencyMHz, sr string) (string, error) {
+	mhz, err := strconv.ParseFloat(strings.TrimSpace(strings.SplitN(frequencyMHz, "/", 2)[0]), 64)
+	if err != nil {
+		return "", fmt.Errorf("bad frequency %q", frequencyMHz)
+	}
+	if ifKHz := mhz*1000 - lmClient.Offset(); ifKHz < kMinTunerKHz || ifKHz > kMaxTunerKHz {
+		return "", fmt.Errorf("%.3f MHz is outside the tuner range", mhz)
+	}
+	kS, err := strconv.Atoi(sr)
+	if err != nil || kS < kMinSymbolRate || kS > kMaxSymbolRate {
+		return "", fmt.Errorf("bad symbol rate %q", sr)
+	}
+	return manualFrequency(mhz), nil
+}
+
+// Replaces the frequency and symbol rate selectors with single values. Must be called with mu held
+func selectManual(freq, sr string) {
+	frequency = newSelector([]string{freq}, freq)
+	symbolRate = newSelector([]string{sr}, sr)
+	somethingChanged(EventSelectionChanged)
+	qLog.Info("Manual selection %v at %v KS", freq, sr)
+}
+
+// Retunes, if tuned, after the fine tuning has changed. Must be called with mu held
+func retune() {
+	if isTuned {
+		lmClient.UnTune()
+		lmClient.Tune(tuneFrequency(), symbolRate.value, bandOptions())
+		isTuned = lmClient.IsTuned()
+		if !isTuned {
+			publish(EventUnTuned)
+		}
+	}
+	spectrumClient.SetMarker(tuneFrequency(), symbolRate.value)
+	publish(EventSelectionChanged)
+}
+
+// Tunes to the current selection. Must be called with mu held
+func tune() {
+	lmClient.Tune(tuneFrequency(), symbolRate.value, bandOptions())
+	isTuned = lmClient.IsTuned()
+	if isTuned {
+		publish(EventTuned)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import "testing"
+
+// with no LNB offset, the frequency is passed to the tuner as it is
+func TestValidateManual(t *testing.T) {
+	tests := []struct {
+		frequency string
+		sr        string
+		want      string
+		wantErr   bool
+	}{
+		{"741.5", "333", "741.500 / --", false},
+		{"144", "33", "144.000 / --", false},
+		{"2450", "27500", "2450.000 / --", false},
+		{" 1296.25 / 00", "1500", "1296.250 / --", false},
+		{"143.999", "333", "", true},  // below the tuner
+		{"2450.001", "333", "", true}, // above the tuner
+		{"ten", "333", "", true},
+		{"741.5", "32", "", true},
+		{"741.5", "27501", "", true},
+		{"741.5", "fast", "", true},
+	}
+	for _, tt := range tests {
+		got, err := validateManual(tt.frequency, tt.sr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateManual(%q, %q) error = %v, want error %v", tt.frequency, tt.sr, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("validateManual(%q, %q) = %q, want %q", tt.frequency, tt.sr, got, tt.want)
+		}
+	}
+}
+
+func TestFineTune(t *testing.T) {
+	frequency = newSelector([]string{"10497.75 / 21"}, "10497.75 / 21")
+	symbolRate = newSelector([]string{"333"}, "333")
+	defer func() { fineKHz = 0 }()
+
+	tests := []struct {
+		delta int
+		want  int
+	}{
+		{25, 25},
+		{-50, -25},
+		{400, 375},
+		{400, kMaxFineKHz},
+		{25, kMaxFineKHz},
+		{-2000, -kMaxFineKHz},
+		{-25, -kMaxFineKHz},
+		{kMaxFineKHz, 0},
+	}
+	for _, tt := range tests {
+		FineTune(tt.delta)
+		if fineKHz != tt.want {
+			t.Errorf("FineTune(%v) fine tuning = %v, want %v", tt.delta, fineKHz, tt.want)
+		}
+	}
+
+	calibrating = true
+	FineTune(25)
+	calibrating = false
+	if fineKHz != 0 {
+		t.Errorf("FineTune while calibrating changed the fine tuning to %v", fineKHz)
+	}
+}
@@ -6,8 +6,12 @@
 package rxControl
 
 import (
+	"errors"
 	"q100receiver-bookworm/lmClient"
 	"q100receiver-bookworm/spectrumClient"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/ea7kir/qLog"
 )
@@ -23,20 +27,44 @@ type (
 		NarrowSymbolrate     string
 		VeryNarrowFrequency  string
 		VeryNarrowSymbolRate string
+		BandOptions          map[string]lmClient.LmOptions // keyed by band, eg. "Narrow"
+		Lnb                  string                        // LnbBandDefault for BandOptions, or lmClient.LnbStatusOff, LnbStatus13V or LnbStatus18V
+		FavouritesFile       string                        // JSON, see Favourite
+		Afc                  bool                          // automatic frequency control, see ToggleAfc
+	}
+	// A copy of the receiver state, safe to use from any go routine
+	State struct {
+		Band         string
+		SymbolRate   string
+		Frequency    string
+		Lnb          string // the LNB selector, see TuConfig.Lnb
+		LnbSupply    string // the LNB supply requested, lmClient.LnbStatusOff, LnbStatus13V or LnbStatus18V
+		IsTuned      bool
+		IsStreaming  bool
+		IsLocked     bool
+		Calibrating  bool
+		Aligning     bool
+		Detecting    bool // the symbol rate, see AutoSymbolRate
+		FineKHz      int  // added to Frequency, see FineTune
+		Afc          bool
+		Deviation    float64 // KHz of the locked carrier from the tuned frequency
+		HasDeviation bool    // Deviation is valid
+		Offset       float64 // LNB offset in KHz
 	}
 )
 
-var (
-	Band       Selector
-	SymbolRate Selector
-	Frequency  Selector
-
-	IsTuned     = false
-	IsStreaming = false
-)
+// Returns the names of the bands
+func Bands() []string {
+	return slices.Clone(const_BAND_LIST)
+}
 
 func Intitialize(cfg TuConfig) {
-	Band = newSelector(const_BAND_LIST, cfg.Band)
+	mu.Lock()
+	defer mu.Unlock()
+
+	tuCfg = cfg
+
+	band = newSelector(const_BAND_LIST, cfg.Band)
 
 	beaconSymbolRate = newSelector(const_BEACON_SYMBOLRATE_LIST, const_BEACON_SYMBOLRATE_LIST[0])
 	beaconFrequency = newSelector(const_BEACON_FREQUENCY_LIST, const_BEACON_FREQUENCY_LIST[0])
@@ -50,78 +78,209 @@ func Intitialize(cfg TuConfig) {
 	veryNarrowSymbolRate = newSelector(const_VERY_NARROW_SYMBOLRATE_LIST, cfg.NarrowSymbolrate)
 	veryNarrowFrequency = newSelector(const_VERY_NARROW_FREQUENCY_LIST, cfg.VeryNarrowFrequency)
 
+	lnb = newSelector(const_LNB_LIST, cfg.Lnb)
+
+	loadFavourites()
+
+	afc = cfg.Afc
+	go runAfc()
+
+	lmClient.OnLockChange(setLocked)
+
 	switchBand()
 }
 
 func Stop() {
 	qLog.Info("Tuner will stop...")
-	if IsTuned {
+	mu.Lock()
+	defer mu.Unlock()
+	if isTuned {
 		lmClient.UnTune()
-		IsTuned = false
+		isTuned = false
+		publish(EventUnTuned)
 	}
 	qLog.Info("Tuner has stopped")
 }
 
+// Returns a copy of the current receiver state
+func Snapshot() State {
+	mu.Lock()
+	defer mu.Unlock()
+	return currentState()
+}
+
 func Tune() {
-	if IsTuned {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if isTuned {
 		lmClient.UnTune()
-		IsTuned = false
+		isTuned = false
+		publish(EventUnTuned)
 	} else {
-		lmClient.Tune(Frequency.Value, SymbolRate.Value)
-		IsTuned = true
+		tune()
 	}
 }
 
-func Stream() {
-	if IsStreaming {
-		IsStreaming = false
-	} else {
-		IsStreaming = true
+// Untunes, if tuned
+func UnTune() {
+	mu.Lock()
+	defer mu.Unlock()
+	if isTuned {
+		lmClient.UnTune()
+		isTuned = false
+		publish(EventUnTuned)
 	}
 }
 
-type Selector struct {
-	currIndex int
-	lastIndex int
-	list      []string
-	Value     string
+// Identifies a tuning by TuneTo, see UnTuneFrom
+type Tuning int
+
+// Untunes, if still tuned as by TuneTo, ie. the selection has not changed since
+func UnTuneFrom(t Tuning) {
+	mu.Lock()
+	defer mu.Unlock()
+	if isTuned && int(t) == selectionGen {
+		lmClient.UnTune()
+		isTuned = false
+		publish(EventUnTuned)
+	}
 }
 
-func IncBandSelector(st *Selector) {
-	if st.currIndex < st.lastIndex {
-		st.currIndex++
-		st.Value = st.list[st.currIndex]
+// Tunes to a frequency in MHz, eg. "10497.75", and symbol rate, eg. "333"
+//
+//	selects the first band with both in its lists, or else tunes the current band
+//	to them as TuneManual would
+func TuneTo(frequencyMHz, sr string) (Tuning, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return 0, errors.New("busy")
+	}
+	for _, b := range const_BAND_LIST {
+		symbolRates, frequencies := bandLists(b)
+		i := slices.IndexFunc(frequencies, func(f string) bool {
+			return strings.HasPrefix(f, frequencyMHz+" ")
+		})
+		if i >= 0 && slices.Contains(symbolRates, sr) {
+			selectAndTune(b, frequencies[i], sr, nil)
+			return Tuning(selectionGen), nil
+		}
+	}
+	freq, err := validateManual(frequencyMHz, sr)
+	if err != nil {
+		return 0, err
+	}
+	selectAndTune(band.value, freq, sr, nil)
+	return Tuning(selectionGen), nil
+}
+
+func Stream() {
+	mu.Lock()
+	defer mu.Unlock()
+	isStreaming = !isStreaming
+	publish(EventStreamChanged)
+}
+
+func IncBand() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if band.inc() {
 		switchBand()
 	}
 }
 
-func DecBandSelector(st *Selector) {
-	if st.currIndex > 0 {
-		st.currIndex--
-		st.Value = st.list[st.currIndex]
+func DecBand() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if band.dec() {
 		switchBand()
 	}
 }
 
-func IncSelector(st *Selector) {
-	if st.currIndex < st.lastIndex {
-		st.currIndex++
-		st.Value = st.list[st.currIndex]
-		somethingChanged()
+func IncSymbolRate() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if symbolRate.inc() {
+		somethingChanged(EventSelectionChanged)
 	}
 }
 
-func DecSelector(st *Selector) {
-	if st.currIndex > 0 {
-		st.currIndex--
-		st.Value = st.list[st.currIndex]
-		somethingChanged()
+func DecSymbolRate() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if symbolRate.dec() {
+		somethingChanged(EventSelectionChanged)
+	}
+}
+
+func IncFrequency() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if frequency.inc() {
+		somethingChanged(EventSelectionChanged)
+	}
+}
+
+func DecFrequency() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if frequency.dec() {
+		somethingChanged(EventSelectionChanged)
 	}
 }
 
+// the LNB selector value that uses the supply of the band or recalled favourite
+const LnbBandDefault = "Band"
+
+// Selects the next LNB supply: Off, 13V vertical, 18V horizontal, the band's default, then Off again
+func NextLnb() {
+	mu.Lock()
+	defer mu.Unlock()
+	if busy() {
+		return
+	}
+	if !lnb.inc() {
+		lnb = newSelector(const_LNB_LIST, const_LNB_LIST[0])
+	}
+	somethingChanged(EventLnbChanged)
+}
+
 // END API ****************************************************
 
 var (
+	const_LNB_LIST = []string{
+		lmClient.LnbStatusOff,
+		lmClient.LnbStatus13V,
+		lmClient.LnbStatus18V,
+		LnbBandDefault,
+	}
+	const_LNB_OPTION = map[string]string{
+		lmClient.LnbStatusOff: lmClient.LnbOff,
+		lmClient.LnbStatus13V: lmClient.LnbVertical,
+		lmClient.LnbStatus18V: lmClient.LnbHorizontal,
+	}
+
 	const_BAND_LIST = []string{
 		"Beacon",
 		"Wide",
@@ -200,16 +359,115 @@ var (
 		"10499.25 / 27",
 	}
 
-	beaconSymbolRate     Selector
-	beaconFrequency      Selector
-	wideSymbolRate       Selector
-	narrowSymbolRate     Selector
-	veryNarrowSymbolRate Selector
-	wideFrequency        Selector
-	narrowFrequency      Selector
-	veryNarrowFrequency  Selector
+	beaconSymbolRate     selector
+	beaconFrequency      selector
+	wideSymbolRate       selector
+	narrowSymbolRate     selector
+	veryNarrowSymbolRate selector
+	wideFrequency        selector
+	narrowFrequency      selector
+	veryNarrowFrequency  selector
+)
+
+// mu guards everything below, and the selectors above
+var (
+	mu          sync.Mutex
+	tuCfg       TuConfig
+	band        selector
+	symbolRate  selector
+	frequency   selector
+	lnb         selector
+	isTuned     bool
+	isStreaming bool
+	isLocked    bool
 )
 
+type selector struct {
+	currIndex int
+	lastIndex int
+	list      []string
+	value     string
+}
+
+// Moves to the next value and returns true if it changed
+func (st *selector) inc() bool {
+	if st.currIndex < st.lastIndex {
+		st.currIndex++
+		st.value = st.list[st.currIndex]
+		return true
+	}
+	return false
+}
+
+// Moves to the previous value and returns true if it changed
+func (st *selector) dec() bool {
+	if st.currIndex > 0 {
+		st.currIndex--
+		st.value = st.list[st.currIndex]
+		return true
+	}
+	return false
+}
+
+// Returns the current state. Must be called with mu held
+func currentState() State {
+	return State{
+		Band:         band.value,
+		SymbolRate:   symbolRate.value,
+		Frequency:    frequency.value,
+		Lnb:          lnb.value,
+		LnbSupply:    lnbSupply(bandOptions().Lnb),
+		IsTuned:      isTuned,
+		IsStreaming:  isStreaming,
+		IsLocked:     isLocked,
+		Calibrating:  calibrating,
+		Aligning:     aligning,
+		Detecting:    detecting,
+		FineKHz:      fineKHz,
+		Afc:          afc,
+		Deviation:    deviationKHz,
+		HasDeviation: hasDeviation,
+		Offset:       lmClient.Offset(),
+	}
+}
+
+// Returns the longmynd options for the current band, or recalled favourite, and LNB supply. Must be called with mu held
+//
+//	the LNB selector wins, unless it is LnbBandDefault. So Off always turns the supply off
+func bandOptions() lmClient.LmOptions {
+	opts, ok := tuCfg.BandOptions[band.value]
+	if !ok {
+		opts = lmClient.DefaultOptions()
+	}
+	if favOptions != nil {
+		opts = *favOptions
+	}
+	if lnb.value != LnbBandDefault {
+		opts.Lnb = const_LNB_OPTION[lnb.value]
+	}
+	return opts
+}
+
+// Returns the LNB status for an LmOptions.Lnb, eg. lmClient.LnbStatus18V for lmClient.LnbHorizontal
+func lnbSupply(option string) string {
+	for status, opt := range const_LNB_OPTION {
+		if opt == option {
+			return status
+		}
+	}
+	return lmClient.LnbStatusOff
+}
+
+// Called from the lmClient decoder whenever the lock state changes
+func setLocked(locked bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if isLocked != locked {
+		isLocked = locked
+		publish(EventLockChanged)
+	}
+}
+
 func indexInList(list []string, with string) int { // TODO: add error check
 	for i := range list {
 		if list[i] == with {
@@ -219,37 +477,67 @@ func indexInList(list []string, with string) int { // TODO: add error check
 	return 0
 }
 
-func newSelector(values []string, with string) Selector {
+func newSelector(values []string, with string) selector {
 	index := indexInList(values, with)
-	st := Selector{
+	st := selector{
 		currIndex: index,
 		lastIndex: len(values) - 1,
 		list:      values,
-		Value:     values[index],
+		value:     values[index],
 	}
 	return st
 }
 
+// Returns the symbol rate and frequency lists of a band
+func bandLists(name string) ([]string, []string) {
+	switch name {
+	case const_BAND_LIST[0]: // beacon
+		return const_BEACON_SYMBOLRATE_LIST, const_BEACON_FREQUENCY_LIST
+	case const_BAND_LIST[1]: // wide
+		return const_WIDE_SYMBOLRATE_LIST, const_WIDE_FREQUENCY_LIST
+	case const_BAND_LIST[2]: // narrow
+		return const_NARROW_SYMBOLRATE_LIST, const_NARROW_FREQUENCY_LIST
+	case const_BAND_LIST[3]: // very narrow
+		return const_VERY_NARROW_SYMBOLRATE_LIST, const_VERY_NARROW_FREQUENCY_LIST
+	}
+	return nil, nil
+}
+
+// Must be called with mu held
 func switchBand() { // TODO: should switch back to previosly use settings
-	switch Band.Value {
+	switch band.value {
 	case const_BAND_LIST[0]: // beacon
-		SymbolRate = beaconSymbolRate
-		Frequency = beaconFrequency
+		symbolRate = beaconSymbolRate
+		frequency = beaconFrequency
 	case const_BAND_LIST[1]: // wide
-		SymbolRate = wideSymbolRate
-		Frequency = wideFrequency
+		symbolRate = wideSymbolRate
+		frequency = wideFrequency
 	case const_BAND_LIST[2]: // narrow
-		SymbolRate = narrowSymbolRate
-		Frequency = narrowFrequency
+		symbolRate = narrowSymbolRate
+		frequency = narrowFrequency
 	case const_BAND_LIST[3]: // very narrow
-		SymbolRate = veryNarrowSymbolRate
-		Frequency = veryNarrowFrequency
+		symbolRate = veryNarrowSymbolRate
+		frequency = veryNarrowFrequency
 	}
-	somethingChanged()
+	somethingChanged(EventBandChanged)
 }
 
-func somethingChanged() {
+// Returns true while calibrating, aligning or detecting the symbol rate, when the
+// selection must not be changed. Must be called with mu held
+func busy() bool {
+	return calibrating || aligning || detecting
+}
+
+// Must be called with mu held
+func somethingChanged(kind EventKind) {
+	favOptions = nil
+	fineKHz = 0
+	selectionGen++
 	lmClient.UnTune()
-	IsTuned = false
-	spectrumClient.SetMarker(Frequency.Value, SymbolRate.Value)
+	if isTuned {
+		isTuned = false
+		publish(EventUnTuned)
+	}
+	spectrumClient.SetMarker(tuneFrequency(), symbolRate.value)
+	publish(kind)
 }
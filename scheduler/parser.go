@@ -0,0 +1,83 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+var kWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Returns the events of a schedule, skipping bad lines and those that validate refuses to tune. For example
+//
+//	# when      UTC    minutes  MHz       KS    record
+//	2024-06-22  19:00  60       10497.75  333   record
+//	daily       07:30  15       10491.50  1500
+//	mon         19:00  60       10497.75  333   record
+func parseSchedule(text string, validate func(frequencyMHz, sr string) error) []Event {
+	var events []Event
+	for i, line := range strings.Split(text, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		ev, err := parseEvent(fields)
+		if err == nil {
+			err = validate(ev.Frequency, ev.SymbolRate)
+		}
+		if err != nil {
+			qLog.Warn("Schedule line %v skipped: %v", i+1, err)
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// Returns the event on one line of the schedule
+func parseEvent(fields []string) (Event, error) {
+	if len(fields) != 5 && !(len(fields) == 6 && fields[5] == "record") {
+		return Event{}, fmt.Errorf("expected: when HH:MM minutes MHz KS [record]")
+	}
+	ev := Event{
+		When:       strings.ToLower(fields[0]),
+		Frequency:  fields[3],
+		SymbolRate: fields[4],
+		Record:     len(fields) == 6,
+	}
+	switch {
+	case ev.When == "daily":
+	case len(ev.When) == 3:
+		if !slices.Contains(kWeekdays, ev.When) {
+			return ev, fmt.Errorf("unknown weekday %q", fields[0])
+		}
+	default:
+		if _, err := time.Parse(time.DateOnly, ev.When); err != nil {
+			return ev, err
+		}
+	}
+	start, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return ev, err
+	}
+	ev.At = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+	minutes, err := strconv.Atoi(fields[2])
+	if err != nil || minutes <= 0 {
+		return ev, fmt.Errorf("bad minutes %q", fields[2])
+	}
+	ev.Duration = time.Duration(minutes) * time.Minute
+	if _, err := strconv.ParseFloat(ev.Frequency, 64); err != nil {
+		return ev, err
+	}
+	return ev, nil
+}
@@ -0,0 +1,139 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    Event
+		wantErr bool
+	}{
+		{"2024-06-22  19:00  60  10497.75  333  record",
+			Event{When: "2024-06-22", At: 19 * time.Hour, Duration: time.Hour, Frequency: "10497.75", SymbolRate: "333", Record: true}, false},
+		{"daily 07:30 15 10491.50 1500",
+			Event{When: "daily", At: 7*time.Hour + 30*time.Minute, Duration: 15 * time.Minute, Frequency: "10491.50", SymbolRate: "1500"}, false},
+		{"MON 00:00 1440 10497.75 333",
+			Event{When: "mon", Duration: 24 * time.Hour, Frequency: "10497.75", SymbolRate: "333"}, false},
+		{"sun 23:59 2 10497.75 333",
+			Event{When: "sun", At: 23*time.Hour + 59*time.Minute, Duration: 2 * time.Minute, Frequency: "10497.75", SymbolRate: "333"}, false},
+		{"daily 07:30 15 10491.50", Event{}, true},                   // too few fields
+		{"daily 07:30 15 10491.50 1500 now", Event{}, true},          // not "record"
+		{"daily 07:30 15 10491.50 1500 record extra", Event{}, true}, // too many fields
+		{"weekly 07:30 15 10491.50 1500", Event{}, true},             // unknown recurrence
+		{"abc 07:30 15 10491.50 1500", Event{}, true},                // unknown weekday
+		{"2024-02-30 07:30 15 10491.50 1500", Event{}, true},         // no such date
+		{"daily 24:00 15 10491.50 1500", Event{}, true},
+		{"daily 7.30 15 10491.50 1500", Event{}, true},
+		{"daily 07:30 0 10491.50 1500", Event{}, true},
+		{"daily 07:30 -5 10491.50 1500", Event{}, true},
+		{"daily 07:30 15 ten 1500", Event{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseEvent(strings.Fields(tt.line))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseEvent(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseEvent(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	text := `# when      UTC    minutes  MHz       KS    record
+2024-06-22  19:00  60       10497.75  333   record
+
+daily       07:30  15       10491.50  1500  # a comment
+bad line
+sat         10:00  30       10497.75  5000
+mon         19:00  60       10497.75  333   record`
+	validate := func(frequencyMHz, sr string) error {
+		if sr == "5000" {
+			return errors.New("bad symbol rate")
+		}
+		return nil
+	}
+	events := parseSchedule(text, validate)
+	var when []string
+	for _, ev := range events {
+		when = append(when, ev.When)
+	}
+	if got, want := strings.Join(when, " "), "2024-06-22 daily mon"; got != want {
+		t.Errorf("parseSchedule events = %q, want %q", got, want)
+	}
+}
+
+func TestFallsOn(t *testing.T) {
+	saturday := time.Date(2024, 6, 22, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		when string
+		date time.Time
+		want bool
+	}{
+		{"daily", saturday, true},
+		{"sat", saturday, true},
+		{"sun", saturday, false},
+		{"sun", saturday.AddDate(0, 0, 1), true},
+		{"2024-06-22", saturday, true},
+		{"2024-06-22", saturday.AddDate(0, 0, 7), false},
+	}
+	for _, tt := range tests {
+		if got := (Event{When: tt.when}).fallsOn(tt.date); got != tt.want {
+			t.Errorf("%q fallsOn %v = %v, want %v", tt.when, tt.date.Format(time.DateOnly), got, tt.want)
+		}
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	events = []Event{
+		{When: "sat", At: 23 * time.Hour, Duration: 2 * time.Hour, Frequency: "10497.75", SymbolRate: "333"},
+		{When: "daily", At: 7 * time.Hour, Duration: 15 * time.Minute, Frequency: "10491.50", SymbolRate: "1500"},
+	}
+	defer func() { events = nil }()
+	active = nil
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		start []time.Time
+	}{
+		// the saturday event runs past midnight, so is still upcoming on sunday
+		{"past midnight", time.Date(2024, 6, 23, 0, 30, 0, 0, time.UTC), []time.Time{
+			time.Date(2024, 6, 22, 23, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 23, 7, 0, 0, 0, time.UTC),
+		}},
+		// an event that has ended is not upcoming
+		{"ended", time.Date(2024, 6, 23, 7, 15, 0, 0, time.UTC), []time.Time{
+			time.Date(2024, 6, 24, 7, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 25, 7, 0, 0, 0, time.UTC),
+		}},
+		// an event under way is upcoming until it ends
+		{"under way", time.Date(2024, 6, 23, 7, 10, 0, 0, time.UTC), []time.Time{
+			time.Date(2024, 6, 23, 7, 0, 0, 0, time.UTC),
+			time.Date(2024, 6, 24, 7, 0, 0, 0, time.UTC),
+		}},
+	}
+	for _, tt := range tests {
+		occs := upcoming(tt.now, len(tt.start))
+		if len(occs) != len(tt.start) {
+			t.Errorf("%v: got %v occurrences, want %v", tt.name, len(occs), len(tt.start))
+			continue
+		}
+		for i, occ := range occs {
+			if !occ.Start.Equal(tt.start[i]) {
+				t.Errorf("%v: occurrence %v starts %v, want %v", tt.name, i, occ.Start, tt.start[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"q100receiver-bookworm/lmClient"
+	"q100receiver-bookworm/rxControl"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+type (
+	ScConfig struct {
+		File         string // the schedule, see parseSchedule
+		RecordFolder string // for recordings, empty for the folder of File
+	}
+	// One line of the schedule file
+	Event struct {
+		When       string        // "daily", a weekday such as "mon", or a date such as "2024-06-22"
+		At         time.Duration // after midnight UTC
+		Duration   time.Duration
+		Frequency  string // MHz, eg. "10497.75"
+		SymbolRate string // eg. "333"
+		Record     bool
+	}
+	// An event at a particular time
+	Occurrence struct {
+		Event
+		Start time.Time
+		End   time.Time
+	}
+)
+
+func Intitialize(cfg ScConfig) {
+	mu.Lock()
+	scCfg = cfg
+	reload()
+	mu.Unlock()
+	go run()
+}
+
+// Returns the active occurrence, if any
+func Active() (Occurrence, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		return Occurrence{}, false
+	}
+	return *active, true
+}
+
+// Returns up to n occurrences which have not yet ended, soonest first
+func Upcoming(n int) []Occurrence {
+	mu.Lock()
+	defer mu.Unlock()
+	return upcoming(time.Now().UTC(), n)
+}
+
+// Ends the active occurrence, if any. Called before shutting down
+func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	if active != nil {
+		finish()
+	}
+}
+
+// END API ****************************************************
+
+const (
+	kCheckInterval = time.Second
+	kReloadEvery   = time.Minute // to pick up changes to the schedule file
+	kLookAhead     = 8           // days
+)
+
+// mu guards everything below
+var (
+	mu       sync.Mutex
+	scCfg    ScConfig
+	events   []Event
+	modTime  time.Time
+	active   *Occurrence
+	tuning   rxControl.Tuning // of the active occurrence
+	failed   *Occurrence      // the last that failed to begin, warned about once
+	lastLoad time.Time
+)
+
+// Starts and ends occurrences forever
+//
+//	an occurrence already under way when the receiver starts is joined for
+//	the remaining time, so the schedule survives restarts
+func run() {
+	for now := range time.Tick(kCheckInterval) {
+		now = now.UTC()
+		mu.Lock()
+		if now.Sub(lastLoad) >= kReloadEvery {
+			reload()
+		}
+		if active != nil && !now.Before(active.End) {
+			finish()
+		}
+		if active == nil {
+			if next := upcoming(now, 1); len(next) > 0 && !now.Before(next[0].Start) {
+				begin(next[0])
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+// Tunes, and starts recording if required. Must be called with mu held
+//
+//	the occurrence only becomes active once tuned, so it is tried again every kCheckInterval
+func begin(occ Occurrence) {
+	t, err := rxControl.TuneTo(occ.Frequency, occ.SymbolRate)
+	if err != nil {
+		if failed == nil || *failed != occ {
+			qLog.Warn("Schedule: failed to tune to %v at %v KS: %v", occ.Frequency, occ.SymbolRate, err)
+		}
+		failed = &occ
+		return
+	}
+	qLog.Info("Schedule: %v at %v KS until %v", occ.Frequency, occ.SymbolRate, occ.End.Format("15:04"))
+	active, tuning, failed = &occ, t, nil
+	if occ.Record {
+		path := recordingPath(occ)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			qLog.Warn("Schedule: failed to create the recording folder: %v", err)
+			return
+		}
+		lmClient.StartRecording(path)
+	}
+}
+
+// Stops recording and untunes, unless the tuning has been changed since. Must be called with mu held
+func finish() {
+	qLog.Info("Schedule: %v at %v KS has ended", active.Frequency, active.SymbolRate)
+	if active.Record {
+		lmClient.StopRecording()
+	}
+	rxControl.UnTuneFrom(tuning)
+	active = nil
+}
+
+// Returns the file for a recording, eg. "20240622-1900-10497.75-333.ts"
+func recordingPath(occ Occurrence) string {
+	folder := scCfg.RecordFolder
+	if folder == "" {
+		folder = filepath.Dir(scCfg.File)
+	}
+	name := occ.Start.Format("20060102-1504") + "-" + occ.Frequency + "-" + occ.SymbolRate + ".ts"
+	return filepath.Join(folder, name)
+}
+
+// Returns up to n occurrences which have not ended by now, soonest first. Must be called with mu held
+func upcoming(now time.Time, n int) []Occurrence {
+	var occs []Occurrence
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for day := -1; day <= kLookAhead; day++ { // from yesterday, in case one runs past midnight
+		date := midnight.AddDate(0, 0, day)
+		for _, ev := range events {
+			if !ev.fallsOn(date) {
+				continue
+			}
+			occ := Occurrence{Event: ev, Start: date.Add(ev.At)}
+			occ.End = occ.Start.Add(ev.Duration)
+			if occ.End.After(now) && (active == nil || !occ.Start.Equal(active.Start) || occ.Event != active.Event) {
+				occs = append(occs, occ)
+			}
+		}
+	}
+	slices.SortStableFunc(occs, func(a, b Occurrence) int {
+		return a.Start.Compare(b.Start)
+	})
+	return occs[:min(n, len(occs))]
+}
+
+// Returns true if the event happens on date
+func (ev Event) fallsOn(date time.Time) bool {
+	switch {
+	case ev.When == "daily":
+		return true
+	case len(ev.When) == 3:
+		return ev.When == strings.ToLower(date.Weekday().String()[:3])
+	}
+	return ev.When == date.Format(time.DateOnly)
+}
+
+// Reads the schedule file if it has changed. Must be called with mu held
+func reload() {
+	lastLoad = time.Now().UTC()
+	if scCfg.File == "" {
+		return
+	}
+	info, err := os.Stat(scCfg.File)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			qLog.Warn("Failed to read the schedule: %v", err)
+		}
+		events = nil
+		return
+	}
+	if info.ModTime().Equal(modTime) {
+		return
+	}
+	modTime = info.ModTime()
+	data, err := os.ReadFile(scCfg.File)
+	if err != nil {
+		qLog.Warn("Failed to read the schedule: %v", err)
+		return
+	}
+	events = parseSchedule(string(data), rxControl.ValidateManual)
+	qLog.Info("Schedule has %v events", len(events))
+}
@@ -0,0 +1,231 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"q100receiver-bookworm/lmClient"
+	"q100receiver-bookworm/rxControl"
+	"q100receiver-bookworm/spectrumClient"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+type (
+	StConfig struct {
+		File   string   // JSON, written by Apply
+		Urls   []string // spectrum websockets to choose from, as there is no keyboard
+		Themes []string // the names of the UI themes, the first is the default
+	}
+	// The settings that can be changed from the touchscreen
+	Settings struct {
+		Volume        int     `json:"volume"`       // 0 to 100
+		LnbOffset     float64 `json:"-"`            // KHz, kept in LmConfig.OffsetFile
+		Url           string  `json:"url"`          // spectrum websocket
+		DefaultBand   string  `json:"default_band"` // used from the next start
+		AverageFactor float32 `json:"average_factor"`
+		HoldDecay     float32 `json:"hold_decay"`      // dB per frame
+		BeaconAlarmDb float32 `json:"beacon_alarm_db"` // zero for no alarm
+		Theme         string  `json:"theme"`           // one of StConfig.Themes
+	}
+)
+
+// limits checked by Validate
+const (
+	MinVolume        = 0
+	MaxVolume        = 100
+	MinLnbOffset     = 9000000 // KHz, checked by Apply only when the offset is changed
+	MaxLnbOffset     = 11000000
+	MinAverageFactor = 0.05
+	MaxAverageFactor = 1
+	MinHoldDecay     = 0.01
+	MaxHoldDecay     = 1
+	MinBeaconAlarmDb = 0
+	MaxBeaconAlarmDb = 20
+)
+
+// Reads StConfig.File and overlays the settings on the configurations,
+// which must not yet have been used. Invalid settings are ignored
+func Intitialize(cfg StConfig, sp *spectrumClient.SpConfig, lm *lmClient.LmConfig, fp *lmClient.FpConfig, tu *rxControl.TuConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	stCfg = cfg
+	themes = cfg.Themes
+
+	volume, _ := strconv.Atoi(fp.Volume)
+	current = Settings{
+		Volume:        volume,
+		LnbOffset:     lm.Offset, // until lmClient has read LmConfig.OffsetFile
+		Url:           sp.Url,
+		DefaultBand:   tu.Band,
+		AverageFactor: sp.AverageFactor,
+		HoldDecay:     sp.HoldDecay,
+		BeaconAlarmDb: sp.BeaconAlarmDb,
+	}
+	if current.AverageFactor == 0 {
+		current.AverageFactor = kDefaultAverageFactor
+	}
+	if current.HoldDecay == 0 {
+		current.HoldDecay = kDefaultHoldDecay
+	}
+	if len(themes) > 0 {
+		current.Theme = themes[0]
+	}
+	if s, ok := load(current); ok {
+		current = s
+	}
+
+	fp.Volume = strconv.Itoa(current.Volume)
+	sp.Url = current.Url
+	sp.AverageFactor = current.AverageFactor
+	sp.HoldDecay = current.HoldDecay
+	sp.BeaconAlarmDb = current.BeaconAlarmDb
+	tu.Band = current.DefaultBand
+}
+
+// Returns the current settings
+func Current() Settings {
+	mu.Lock()
+	defer mu.Unlock()
+	s := current
+	s.LnbOffset = lmClient.Offset()
+	return s
+}
+
+// Returns the spectrum websockets to choose from, including the current one
+func Urls() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	urls := slices.Clone(stCfg.Urls)
+	if !slices.Contains(urls, current.Url) {
+		urls = append(urls, current.Url)
+	}
+	return urls
+}
+
+// Returns an error for the first setting that is out of range
+//
+//	except the LNB offset, which is kept elsewhere and may be anything until it is changed
+func (s Settings) Validate() error {
+	switch {
+	case s.Volume < MinVolume || s.Volume > MaxVolume:
+		return fmt.Errorf("volume must be %v to %v", MinVolume, MaxVolume)
+	case !strings.HasPrefix(s.Url, "ws://") && !strings.HasPrefix(s.Url, "wss://"):
+		return errors.New("the spectrum URL must start with ws:// or wss://")
+	case !slices.Contains(rxControl.Bands(), s.DefaultBand):
+		return fmt.Errorf("unknown band %q", s.DefaultBand)
+	case s.AverageFactor < MinAverageFactor || s.AverageFactor > MaxAverageFactor:
+		return fmt.Errorf("average factor must be %v to %v", MinAverageFactor, MaxAverageFactor)
+	case s.HoldDecay < MinHoldDecay || s.HoldDecay > MaxHoldDecay:
+		return fmt.Errorf("hold decay must be %v to %v dB", MinHoldDecay, MaxHoldDecay)
+	case s.BeaconAlarmDb < MinBeaconAlarmDb || s.BeaconAlarmDb > MaxBeaconAlarmDb:
+		return fmt.Errorf("beacon alarm must be %v to %v dB", MinBeaconAlarmDb, MaxBeaconAlarmDb)
+	case len(themes) > 0 && !slices.Contains(themes, s.Theme):
+		return fmt.Errorf("unknown theme %q", s.Theme)
+	}
+	return nil
+}
+
+// Validates the settings, applies those that have changed and saves them to StConfig.File
+//
+//	the default band is used from the next start. The UI follows the theme of Current
+func Apply(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	offsetChanged := s.LnbOffset != lmClient.Offset()
+	if offsetChanged && (s.LnbOffset < MinLnbOffset || s.LnbOffset > MaxLnbOffset) {
+		return fmt.Errorf("LNB offset must be %v to %v KHz", MinLnbOffset, MaxLnbOffset)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	if s.Volume != current.Volume {
+		lmClient.SetVolume(s.Volume)
+	}
+	if offsetChanged {
+		if err := lmClient.SetOffset(s.LnbOffset); err != nil {
+			return fmt.Errorf("failed to save the LNB offset: %v", err)
+		}
+	}
+	if s.Url != current.Url {
+		spectrumClient.SetUrl(s.Url)
+	}
+	if s.AverageFactor != current.AverageFactor || s.HoldDecay != current.HoldDecay {
+		spectrumClient.SetProcessing(s.AverageFactor, s.HoldDecay)
+	}
+	if s.BeaconAlarmDb != current.BeaconAlarmDb {
+		spectrumClient.SetBeaconAlarm(s.BeaconAlarmDb)
+	}
+	current = s
+	qLog.Info("Settings applied")
+	return save()
+}
+
+// END API ****************************************************
+
+// the same as spectrumClient, to show when they are not configured
+const (
+	kDefaultAverageFactor = 0.2
+	kDefaultHoldDecay     = 0.01
+)
+
+// guarded by mu
+var (
+	mu      sync.Mutex
+	stCfg   StConfig
+	current Settings
+)
+
+// StConfig.Themes, set by Intitialize and read by Validate
+var themes []string
+
+// Returns the defaults overlaid with StConfig.File, and true if they are valid.
+// Must be called with mu held
+func load(defaults Settings) (Settings, bool) {
+	if stCfg.File == "" {
+		return defaults, false
+	}
+	data, err := os.ReadFile(stCfg.File)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			qLog.Warn("Failed to read settings: %v", err)
+		}
+		return defaults, false
+	}
+	s := defaults
+	if err := json.Unmarshal(data, &s); err != nil {
+		qLog.Warn("Bad settings in %v: %v", stCfg.File, err)
+		return defaults, false
+	}
+	if err := s.Validate(); err != nil {
+		qLog.Warn("Settings in %v are ignored: %v", stCfg.File, err)
+		return defaults, false
+	}
+	qLog.Info("Settings loaded from %v", stCfg.File)
+	return s, true
+}
+
+// Writes the settings to StConfig.File. Must be called with mu held
+func save() error {
+	if stCfg.File == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stCfg.File, append(data, '\n'), 0644)
+}
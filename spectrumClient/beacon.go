@@ -0,0 +1,120 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package spectrumClient
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API *******************************************************
+
+// The beacon SNR history
+type BeaconTrend struct {
+	Samples  []float32 // dB, oldest first
+	Capacity int       // the maximum number of samples
+	Interval time.Duration
+}
+
+// Returns a copy of the beacon SNR history
+func BeaconHistory() BeaconTrend {
+	beaconMu.Lock()
+	defer beaconMu.Unlock()
+	trend := BeaconTrend{
+		Samples:  make([]float32, 0, beaconCount),
+		Capacity: len(beaconSamples),
+		Interval: beaconInterval,
+	}
+	for i := 0; i < beaconCount; i++ {
+		trend.Samples = append(trend.Samples, beaconSamples[(beaconNext-beaconCount+i+len(beaconSamples))%len(beaconSamples)])
+	}
+	return trend
+}
+
+// Sets the beacon alarm threshold in dB, zero for no alarm
+func SetBeaconAlarm(db float32) {
+	beaconMu.Lock()
+	defer beaconMu.Unlock()
+	beaconAlarmDb = db
+}
+
+// END API *******************************************************
+
+const (
+	kDefaultBeaconInterval = 10 * time.Second
+	kDefaultBeaconHistory  = 4 * time.Hour
+	kBeaconAlarmHysteresis = 0.5 // dB
+)
+
+var (
+	beaconMu       sync.Mutex
+	beaconInterval = kDefaultBeaconInterval
+	beaconSamples  = make([]float32, int(kDefaultBeaconHistory/kDefaultBeaconInterval))
+	beaconNext     int // index of the next sample
+	beaconCount    int // number of valid samples
+	beaconAlarmDb  float32
+	beaconSum      float32 // for the sample being averaged
+	beaconFrames   int
+	beaconStarted  time.Time
+)
+
+// Sets the beacon history and alarm from the configuration
+func setBeacon(cfg SpConfig) {
+	if cfg.BeaconInterval > 0 {
+		beaconInterval = cfg.BeaconInterval
+	}
+	history := kDefaultBeaconHistory
+	if cfg.BeaconHistory > 0 {
+		history = cfg.BeaconHistory
+	}
+	beaconSamples = make([]float32, max(int(history/beaconInterval), 2))
+	beaconAlarmDb = cfg.BeaconAlarmDb
+}
+
+// Sets the beacon SNR, level and alarm in spData, and adds to the history
+//
+//	the SNR is the mean level of the beacon's central bins above the noise floor
+func trackBeacon(now time.Time) {
+	halfWidth := kBeaconSymbolRate / 1000 / 2
+	snr := BandDb(spData.Db, kBeaconFrequency-halfWidth, kBeaconFrequency+halfWidth)
+	spData.BeaconSnr = snr
+	spData.BeaconLevel = DbToY(snr)
+
+	if beaconStarted.IsZero() {
+		beaconStarted = now
+	}
+	beaconSum += snr
+	beaconFrames++
+	if now.Sub(beaconStarted) < beaconInterval {
+		return
+	}
+	sample := beaconSum / float32(beaconFrames)
+	beaconSum = 0
+	beaconFrames = 0
+	beaconStarted = now
+
+	beaconMu.Lock()
+	beaconSamples[beaconNext] = sample
+	beaconNext = (beaconNext + 1) % len(beaconSamples)
+	beaconCount = min(beaconCount+1, len(beaconSamples))
+	alarmDb := beaconAlarmDb
+	beaconMu.Unlock()
+
+	if alarmDb <= 0 {
+		spData.BeaconAlarm = false
+		return
+	}
+	switch {
+	case !spData.BeaconAlarm && sample < alarmDb:
+		spData.BeaconAlarm = true
+		qLog.Warn("Beacon alarm: SNR %.1f dB is below %.1f dB", sample, alarmDb)
+	case spData.BeaconAlarm && sample >= alarmDb+kBeaconAlarmHysteresis:
+		spData.BeaconAlarm = false
+		qLog.Info("Beacon alarm cleared: SNR %.1f dB", sample)
+	}
+}
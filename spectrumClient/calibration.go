@@ -0,0 +1,221 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package spectrumClient
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ea7kir/qLog"
+)
+
+/*****************************************************************
+* SPECTRUM & CALIBRARTION MARKERS
+*****************************************************************/
+
+// BEGIN API *******************************************************
+
+// Returns the downlink frequency in MHz at the centre of an FFT bin
+func BinToFrequency(bin float32) float64 {
+	return startFrequency + float64(bin)*binWidth()
+}
+
+// Returns the FFT bin, possibly fractional, for a downlink frequency in MHz
+func FrequencyToBin(mhz float64) float32 {
+	return float32((mhz - startFrequency) / binWidth())
+}
+
+// Returns the X coordinate, 0.0 to 100.0, of an FFT bin
+func BinToX(bin float32) float32 {
+	return 100.0 * bin / float32(numPoints)
+}
+
+// Returns the X coordinate, 0.0 to 100.0, of a downlink frequency in MHz
+func FrequencyToX(mhz float64) float32 {
+	return BinToX(FrequencyToBin(mhz))
+}
+
+// Returns the downlink frequency in MHz of an X coordinate, 0.0 to 100.0
+func XToFrequency(x float32) float64 {
+	return BinToFrequency(x * float32(numPoints) / 100.0)
+}
+
+// Returns the downlink frequency in MHz of a QO-100 channel, 1 to 27
+//
+//	channel 0 is the beacon
+func ChannelFrequency(channel int) float64 {
+	if channel == 0 {
+		return kBeaconFrequency
+	}
+	return kChannel0Frequency + float64(channel)*kChannelSpacing
+}
+
+// Returns the QO-100 channel nearest to a downlink frequency in MHz, 0 for the
+// beacon, or -1 if the frequency is outside the band
+func FrequencyChannel(mhz float64) int {
+	if math.Abs(mhz-kBeaconFrequency) < kChannelSpacing {
+		return 0
+	}
+	channel := int(math.Round((mhz - kChannel0Frequency) / kChannelSpacing))
+	if channel < 1 || channel > 27 {
+		return -1
+	}
+	return channel
+}
+
+// Returns the frequency in MHz from a string such as "10491.50 / 00"
+func ParseFrequency(frequency string) (float64, error) {
+	return strconv.ParseFloat(strings.SplitN(frequency, " ", 2)[0], 64)
+}
+
+// Returns the occupied bandwidth in MHz of a symbol rate in KS
+func SymbolRateBandwidth(kSymbols float64) float64 {
+	return kSymbols * (1 + kRollOff) / 1000
+}
+
+// Logs the measured position of the beacon in the latest frame, and the
+// difference between the calculated and hand measured channel positions
+//
+//	returns the beacon error in KHz
+func VerifyCalibration() float64 {
+	measured := beaconCentroid(spData.Db)
+	errorKHz := (measured - kBeaconFrequency) * 1000
+	qLog.Info("Spectrum %.3f to %.3f MHz: beacon expected at %.3f, measured at %.3f MHz, error %.0f KHz",
+		startFrequency, stopFrequency, kBeaconFrequency, measured, errorKHz)
+	worst := float32(0)
+	for frequency, bin := range kMeasuredBins {
+		mhz, _ := ParseFrequency(frequency)
+		diff := FrequencyToBin(mhz) - bin
+		if float32(math.Abs(float64(diff))) > float32(math.Abs(float64(worst))) {
+			worst = diff
+		}
+	}
+	qLog.Info("Spectrum worst difference from measured channel positions %.1f bins (%.0f KHz)",
+		worst, float64(worst)*binWidth()*1000)
+	beaconMu.Lock()
+	defer beaconMu.Unlock()
+	beaconErrorKHz, beaconVerified = errorKHz, true
+	return errorKHz
+}
+
+// Returns the beacon error in KHz found by VerifyCalibration, and false until it has run
+func CalibrationError() (float64, bool) {
+	beaconMu.Lock()
+	defer beaconMu.Unlock()
+	return beaconErrorKHz, beaconVerified
+}
+
+// END API *******************************************************
+
+const (
+	kDefaultStartFrequency = 10490.50 // fitted to kMeasuredBins
+	kDefaultStopFrequency  = 10499.48 // fitted to kMeasuredBins
+	kBeaconFrequency       = 10491.50
+	kBeaconSymbolRate      = 1500.0
+	kChannel0Frequency     = 10492.50
+	kChannelSpacing        = 0.25
+	kRollOff               = 0.35
+)
+
+var (
+	startFrequency = kDefaultStartFrequency
+	stopFrequency  = kDefaultStopFrequency
+)
+
+// guarded by beaconMu
+var (
+	beaconErrorKHz float64
+	beaconVerified bool
+)
+
+// bins measured by hand before the calibration was calculated, used by VerifyCalibration
+var kMeasuredBins = map[string]float32{
+	"10491.50 / 00": 103,
+	"10492.75 / 01": 230,
+	"10493.00 / 02": 256,
+	"10493.25 / 03": 281,
+	"10493.50 / 04": 307,
+	"10493.75 / 05": 332,
+	"10494.00 / 06": 358,
+	"10494.25 / 07": 383,
+	"10494.50 / 08": 409,
+	"10494.75 / 09": 434,
+	"10495.00 / 10": 460,
+	"10495.25 / 11": 485,
+	"10495.50 / 12": 511,
+	"10495.75 / 13": 536,
+	"10496.00 / 14": 562,
+	"10496.25 / 15": 588,
+	"10496.50 / 16": 613,
+	"10496.75 / 17": 639,
+	"10497.00 / 18": 664,
+	"10497.25 / 19": 690,
+	"10497.50 / 20": 715,
+	"10497.75 / 21": 741,
+	"10498.00 / 22": 767,
+	"10498.25 / 23": 792,
+	"10498.50 / 24": 818,
+	"10498.75 / 25": 843,
+	"10499.00 / 26": 869,
+	"10499.25 / 27": 894,
+}
+
+// Sets the frequency range of the 918 bins
+func setCalibration(cfg SpConfig) {
+	if cfg.StartFrequency != 0 && cfg.StopFrequency > cfg.StartFrequency {
+		startFrequency = cfg.StartFrequency
+		stopFrequency = cfg.StopFrequency
+	}
+}
+
+// Returns the width of one FFT bin in MHz
+func binWidth() float64 {
+	return (stopFrequency - startFrequency) / float64(numPoints)
+}
+
+// Returns frequency and bandWidth Markers as float32
+func getMarkers(frequency, symbolRate string) (float32, float32) {
+	mhz, err := ParseFrequency(frequency)
+	if err != nil {
+		qLog.Warn("Bad marker frequency %q: %v", frequency, err)
+		return 0, 0
+	}
+	kSymbols, err := strconv.ParseFloat(symbolRate, 64)
+	if err != nil {
+		qLog.Warn("Bad marker symbol rate %q: %v", symbolRate, err)
+		return FrequencyToX(mhz), 0
+	}
+	centre := FrequencyToX(mhz)
+	width := FrequencyToX(mhz+SymbolRateBandwidth(kSymbols)) - centre
+	return centre, width
+}
+
+// Returns the frequency in MHz of the centre of the beacon, from the bins above
+// half its peak level within its expected bandwidth
+func beaconCentroid(db []float32) float64 {
+	halfWidth := SymbolRateBandwidth(kBeaconSymbolRate)
+	first := int(FrequencyToBin(kBeaconFrequency - halfWidth))
+	last := int(FrequencyToBin(kBeaconFrequency + halfWidth))
+	first = max(first, 1)
+	last = min(last, numPoints-2)
+
+	var peak float32
+	for i := first; i <= last; i++ {
+		peak = max(peak, db[i])
+	}
+	var sum, weight float32
+	for i := first; i <= last; i++ {
+		if db[i] >= peak/2 {
+			sum += float32(i) * db[i]
+			weight += db[i]
+		}
+	}
+	if weight == 0 {
+		return math.NaN()
+	}
+	return BinToFrequency(sum / weight)
+}
@@ -0,0 +1,46 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package spectrumClient
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFrequencyToBin(t *testing.T) {
+	for frequency, bin := range kMeasuredBins {
+		mhz, err := ParseFrequency(frequency)
+		if err != nil {
+			t.Fatalf("ParseFrequency(%q): %v", frequency, err)
+		}
+		if got := FrequencyToBin(mhz); math.Abs(float64(got-bin)) > 1 {
+			t.Errorf("FrequencyToBin(%v) = %.1f, measured %v", mhz, got, bin)
+		}
+		if got := BinToFrequency(FrequencyToBin(mhz)); math.Abs(got-mhz) > 1e-3 {
+			t.Errorf("BinToFrequency(FrequencyToBin(%v)) = %v", mhz, got)
+		}
+	}
+}
+
+func TestFrequencyChannel(t *testing.T) {
+	tests := []struct {
+		mhz  float64
+		want int
+	}{
+		{kBeaconFrequency, 0},
+		{10492.75, 1},
+		{10492.80, 1},
+		{10497.75, 21},
+		{10499.25, 27},
+		{10499.50, -1},
+		{10490.00, -1},
+	}
+	for _, tt := range tests {
+		if got := FrequencyChannel(tt.mhz); got != tt.want {
+			t.Errorf("FrequencyChannel(%v) = %v, want %v", tt.mhz, got, tt.want)
+		}
+	}
+}
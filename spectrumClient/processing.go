@@ -0,0 +1,149 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package spectrumClient
+
+import (
+	"sync"
+)
+
+// BEGIN API *******************************************************
+
+type Mode int
+
+const (
+	ModeLive    Mode = iota // every frame as received
+	ModeAverage             // exponential average of the frames
+	ModeMaxHold             // live trace with a held maximum that decays
+	ModeMinHold             // live trace with a held minimum
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeAverage:
+		return "Average"
+	case ModeMaxHold:
+		return "Max Hold"
+	case ModeMinHold:
+		return "Min Hold"
+	}
+	return "Live"
+}
+
+// Sets the processing mode and resets the average and held traces
+func SetMode(mode Mode) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	procMode = mode
+	resetPending = true
+}
+
+// Selects the next processing mode, and returns it
+func NextMode() Mode {
+	procMu.Lock()
+	defer procMu.Unlock()
+	procMode = (procMode + 1) % (ModeMinHold + 1)
+	resetPending = true
+	return procMode
+}
+
+// Restarts the average and held traces from the next frame
+func Reset() {
+	procMu.Lock()
+	defer procMu.Unlock()
+	resetPending = true
+}
+
+// Sets the averaging factor and the hold decay in dB per frame
+//
+//	called from the settings. Values out of range are ignored
+func SetProcessing(factor, decay float32) {
+	procMu.Lock()
+	defer procMu.Unlock()
+	if factor > 0 && factor <= 1 {
+		averageFactor = factor
+	}
+	if decay > 0 {
+		holdDecay = decay
+	}
+}
+
+// END API *******************************************************
+
+const (
+	kDefaultAverageFactor = 0.2  // weight of each new frame
+	kDefaultHoldDecay     = 0.01 // dB per frame
+)
+
+var (
+	procMu        sync.Mutex
+	procMode      = ModeLive
+	resetPending  = true
+	averageFactor = float32(kDefaultAverageFactor)
+	holdDecay     = float32(kDefaultHoldDecay)
+	average       = make([]float32, numPoints)
+	held          = make([]float32, numPoints) // dB
+	heldY         = make([]float32, numPoints)
+)
+
+// Sets the averaging factor and hold decay from the configuration
+func setProcessing(cfg SpConfig) {
+	if cfg.AverageFactor > 0 && cfg.AverageFactor <= 1 {
+		averageFactor = cfg.AverageFactor
+	}
+	if cfg.HoldDecay > 0 {
+		holdDecay = cfg.HoldDecay
+	}
+}
+
+// Applies the processing mode to a live frame in spData.Db
+//
+//	in ModeAverage, Db is replaced by the average. In the hold modes, spData.Held
+//	is set to the Y coordinates of the held trace, otherwise it is nil
+func process() {
+	procMu.Lock()
+	mode := procMode
+	reset := resetPending
+	resetPending = false
+	factor := averageFactor
+	decay := holdDecay
+	procMu.Unlock()
+
+	spData.Mode = mode
+	spData.Held = nil
+
+	if reset {
+		copy(average, spData.Db)
+		copy(held, spData.Db)
+	}
+
+	switch mode {
+	case ModeAverage:
+		for i, db := range spData.Db {
+			average[i] += factor * (db - average[i])
+		}
+		copy(spData.Db, average)
+	case ModeMaxHold:
+		for i, db := range spData.Db {
+			held[i] = max(held[i]-decay, db)
+		}
+		spData.Held = heldToY()
+	case ModeMinHold:
+		for i, db := range spData.Db {
+			held[i] = min(held[i], db)
+		}
+		spData.Held = heldToY()
+	}
+}
+
+// Returns the Y coordinates of the held trace
+func heldToY() []float32 {
+	for i, db := range held {
+		heldY[i] = DbToY(db)
+	}
+	heldY[0] = 0
+	heldY[numPoints-1] = 0
+	return heldY
+}
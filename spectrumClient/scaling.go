@@ -0,0 +1,74 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package spectrumClient
+
+import (
+	"slices"
+)
+
+// BEGIN API *******************************************************
+
+// Returns the Y coordinate, 0.0 to 100.0, of a level in dB above the noise floor
+//
+//	the noise floor is drawn at kYBase, and DbRange dB fills the rest of the display
+func DbToY(db float32) float32 {
+	y := kYBase + db*(100-kYBase)/DbRange
+	return min(max(y, 0), 100)
+}
+
+// Returns the mean level in dB above the noise floor of the bins between two frequencies in MHz
+func BandDb(db []float32, fromMHz, toMHz float64) float32 {
+	first := max(int(FrequencyToBin(fromMHz)), 1)
+	last := min(int(FrequencyToBin(toMHz)), len(db)-2)
+	if last < first {
+		return 0
+	}
+	var sum float32
+	for i := first; i <= last; i++ {
+		sum += db[i]
+	}
+	return sum / float32(last-first+1)
+}
+
+// the dB range of the display above the noise floor
+const DbRange = 16.5
+
+// END API *******************************************************
+
+const (
+	// the BATC wideband FFT is log scaled. Full scale, 65536, is taken to be 20 dB
+	kDefaultCountsPerDb = 65536.0 / 20.0
+	// the noise floor is the level below which this fraction of the bins fall.
+	// Low enough to ignore the beacon and busy transponder, high enough to ignore nulls
+	kNoiseFloorPercentile = 0.2
+	// Y coordinate of the noise floor
+	kYBase = 3
+)
+
+var (
+	countsPerDb = float32(kDefaultCountsPerDb)
+	sorted      = make([]float32, numPoints)
+)
+
+// Sets the dB scale from the configuration
+func setScaling(cfg SpConfig) {
+	if cfg.CountsPerDb > 0 {
+		countsPerDb = cfg.CountsPerDb
+	}
+}
+
+// Returns the level of a BATC FFT word in dB
+func wordToDb(word uint16) float32 {
+	return float32(word) / countsPerDb
+}
+
+// Returns the estimated noise floor in dB of a frame, ignoring the end points
+func noiseFloor(levels []float32) float32 {
+	inner := sorted[:len(levels)-2]
+	copy(inner, levels[1:len(levels)-1])
+	slices.Sort(inner)
+	return inner[int(float32(len(inner)-1)*kNoiseFloorPercentile)]
+}
@@ -6,7 +6,11 @@
 package spectrumClient
 
 import (
+	"errors"
 	"os"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/ea7kir/qLog"
 	"golang.org/x/net/websocket"
@@ -14,12 +18,26 @@ import (
 
 type (
 	SpConfig struct {
-		Url    string
-		Origin string
+		Url            string
+		Origin         string
+		StartFrequency float64       // MHz at bin 0, zero for the default
+		StopFrequency  float64       // MHz at bin 918, zero for the default
+		AverageFactor  float32       // weight of each new frame in ModeAverage, zero for the default
+		HoldDecay      float32       // dB per frame in ModeMaxHold, zero for the default
+		CountsPerDb    float32       // BATC FFT counts per dB, zero for the default
+		BeaconInterval time.Duration // between samples of the beacon history, zero for the default
+		BeaconHistory  time.Duration // length of the beacon history, zero for the default
+		BeaconAlarmDb  float32       // alarm when the beacon SNR falls below this, zero for no alarm
 	}
 	SpData struct {
-		Yp           []float32
-		BeaconLevel  float32
+		Yp           []float32 // Y coordinates from 0.0 to 100.0, see DbToY
+		Db           []float32 // dB above the noise floor, after processing
+		NoiseFloor   float32   // dB on the BATC scale
+		Held         []float32 // the held trace in ModeMaxHold and ModeMinHold, otherwise nil
+		Mode         Mode
+		BeaconLevel  float32 // Y coordinate of BeaconSnr
+		BeaconSnr    float32 // dB above the noise floor
+		BeaconAlarm  bool    // BeaconSnr is below SpConfig.BeaconAlarmDb
 		MarkerCentre float32
 		MarkerWidth  float32
 	}
@@ -31,26 +49,91 @@ var (
 
 func Intitialize(cfg SpConfig, ch chan SpData) {
 	// spChannel = ch
+	setCalibration(cfg)
+	setProcessing(cfg)
+	setScaling(cfg)
+	setBeacon(cfg)
 	Xp[0] = 0
 	for i := 1; i < numPoints-1; i++ {
-		Xp[i] = 100.0 * (float32(i) / float32(numPoints))
+		Xp[i] = BinToX(float32(i))
 	}
 	Xp[numPoints-1] = 100
 
-	go readAndDecode(cfg, ch)
+	wsUrl = cfg.Url
+	wsOrigin = cfg.Origin
+	go readAndDecode(ch)
 }
 
+// Closes the websocket and ends the go routine started by Intitialize
 func Stop() {
-	qLog.Warn("Spectrum will stop... - NOT IMPLELENTED")
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	if wsStopped {
+		return
+	}
+	qLog.Info("Spectrum will stop...")
+	wsStopped = true
+	close(wsDone)
+	if wsConn != nil {
+		wsConn.Close()
+		wsConn = nil
+		wsSince = time.Now()
+	}
 }
 
 // Sets the spData Marker values
 //
 //	called from rxControl or tx Control
 func SetMarker(frequency string, symbolRate string) {
-	spData.MarkerCentre, spData.MarkerWidth = getMarkers(frequency, symbolRate)
-	// spData.MarkerCentre = const_frequencyCentre[frequency]
-	// spData.MarkerWidth = const_symbolRateWidth[symbolRate]
+	centre, width := getMarkers(frequency, symbolRate)
+	markerMu.Lock()
+	defer markerMu.Unlock()
+	markerCentre, markerWidth = centre, width
+}
+
+// The state of the websocket connection
+type Connection struct {
+	Url       string
+	Connected bool
+	Since     time.Time // when it connected, or disconnected
+	LastFrame time.Time // zero if none has been received
+}
+
+// Returns the state of the websocket connection
+func ConnectionState() Connection {
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	return Connection{
+		Url:       wsUrl,
+		Connected: wsConn != nil,
+		Since:     wsSince,
+		LastFrame: wsLastFrame,
+	}
+}
+
+// Returns the websocket URL
+func Url() string {
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	return wsUrl
+}
+
+// Sets the websocket URL and reconnects to it
+//
+//	called from the settings
+func SetUrl(url string) {
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	if url == wsUrl {
+		return
+	}
+	qLog.Info("Spectrum will reconnect to %v", url)
+	wsUrl = url
+	if wsConn != nil {
+		wsConn.Close()
+		wsConn = nil
+		wsSince = time.Now()
+	}
 }
 
 // END API *******************************************************
@@ -58,34 +141,163 @@ func SetMarker(frequency string, symbolRate string) {
 // room for 916 datapoints + start and end zero points to close the polygon
 const numPoints = 918
 
+// enough frames for the spectrum to settle before logging the calibration
+const kVerifyAfterFrames = 100
+
+// between attempts to reconnect after the URL has changed
+const kRedialDelay = 5 * time.Second
+
 var (
 	spData = SpData{
-		Yp:           make([]float32, numPoints),
-		BeaconLevel:  0.5,
-		MarkerCentre: 0.5,
-		MarkerWidth:  0.5,
+		Yp:          make([]float32, numPoints),
+		Db:          make([]float32, numPoints),
+		BeaconLevel: 0.5,
 	}
 )
 
+// set by SetMarker from any go routine, and copied to each frame
+var (
+	markerMu     sync.Mutex
+	markerCentre = float32(0.5)
+	markerWidth  = float32(0.5)
+)
+
+// guarded by wsMu
+var (
+	wsMu        sync.Mutex
+	wsUrl       string
+	wsOrigin    string
+	wsConn      *websocket.Conn // nil while not connected
+	wsSince     time.Time
+	wsLastFrame time.Time
+	wsStopped   bool
+	wsDone      = make(chan struct{}) // closed by Stop
+)
+
 // TODO: needs a timeout. see https://pkg.go.dev/nhooyr.io/websocket
 //	which uses: ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 
 // forever go routine called from Intitialize
-func readAndDecode(cfg SpConfig, ch chan SpData) {
-	ws, err := websocket.Dial(cfg.Url, "", cfg.Origin)
+//
+//	a failure to connect at start up is fatal, later ones are retried
+func readAndDecode(ch chan SpData) {
+	for connected := false; ; {
+		ws, err := dial()
+		if errors.Is(err, errStopped) {
+			qLog.Info("Spectrum has stopped")
+			return
+		}
+		if err != nil {
+			if !connected {
+				qLog.Fatal("Dial failed: %v", err)
+				os.Exit(1)
+			}
+			qLog.Error("Dial failed: %v", err)
+			redialDelay()
+			continue
+		}
+		connected = true
+		if err := decode(ws, ch); err != nil {
+			qLog.Warn("Read failed, will reconnect in %v: %v", kRedialDelay, err)
+			redialDelay()
+		}
+	}
+}
+
+// Waits kRedialDelay, or until Stop
+func redialDelay() {
+	select {
+	case <-time.After(kRedialDelay):
+	case <-wsDone:
+	}
+}
+
+// returned by dial after Stop
+var errStopped = errors.New("the spectrum has stopped")
+
+// Connects to the websocket URL
+func dial() (*websocket.Conn, error) {
+	wsMu.Lock()
+	url, origin, stopped := wsUrl, wsOrigin, wsStopped
+	wsMu.Unlock()
+	if stopped {
+		return nil, errStopped
+	}
+
+	ws, err := websocket.Dial(url, "", origin)
 	if err != nil {
-		qLog.Fatal("Dial failed: %v", err)
-		os.Exit(1)
+		return nil, err
+	}
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	if wsStopped {
+		ws.Close()
+		return nil, errStopped
+	}
+	if url != wsUrl {
+		ws.Close()
+		return nil, errors.New("the URL changed while connecting")
+	}
+	wsConn = ws
+	wsSince = time.Now()
+	qLog.Info("Spectrum connected to %v", url)
+	return ws, nil
+}
+
+// Returns true if ws has been closed by SetUrl or Stop
+func replaced(ws *websocket.Conn) bool {
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	return wsConn != ws
+}
+
+// Returns a copy of spData, with its own slices as spData is overwritten by the next frame while the UI draws this one,
+// and the marker
+func frame() SpData {
+	f := spData
+	markerMu.Lock()
+	f.MarkerCentre, f.MarkerWidth = markerCentre, markerWidth
+	markerMu.Unlock()
+	f.Yp = slices.Clone(spData.Yp)
+	f.Db = slices.Clone(spData.Db)
+	f.Held = slices.Clone(spData.Held) // nil stays nil
+	return f
+}
+
+// Records that ws has failed, if it is still the connection
+func disconnected(ws *websocket.Conn) {
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	if wsConn == ws {
+		wsConn = nil
+		wsSince = time.Now()
 	}
+}
+
+// Records the time of a frame
+func frameReceived(now time.Time) {
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	wsLastFrame = now
+}
+
+// Decodes frames from ws until it is closed by SetUrl or Stop, when it returns nil, or fails
+func decode(ws *websocket.Conn, ch chan SpData) error {
 	defer ws.Close()
 
 	var bytes = make([]byte, 2048) // larger than 1844
+	var levels = make([]float32, numPoints)
 	var n int
+	var err error
+	var frames int
 
 	for {
 		if n, err = ws.Read(bytes); err != nil {
-			qLog.Warn("Read failed: %v", err)
-			continue
+			if replaced(ws) {
+				return nil
+			}
+			disconnected(ws)
+			return err
 		}
 		if n != 1844 {
 			qLog.Warn("reading : bytes != 1844\n")
@@ -93,123 +305,37 @@ func readAndDecode(cfg SpConfig, ch chan SpData) {
 		}
 
 		// begin processing the bytes
-		// count = 0
 		for i := 0; i < 1836; {
 			word := uint16(bytes[i]) + uint16(bytes[i+1])<<8
-			// count++
-			// qLog.Info("count = %v\n", count)
-			if word < 8192 {
-				word = 8192
-			}
-			// spData.Yp[i/2] = float32(word-uint16(8192)) / float32(52000)
-			spData.Yp[i/2] = float32(word-uint16(8192)) / float32(520) // normalize to 0 to 100
-			// spData.Yp[i/2] = 50.0
+			levels[i/2] = wordToDb(word)
 			i += 2
 		}
-		// qLog.Info("count = %v\n", count)
-		spData.Yp[0] = 0
-		spData.Yp[numPoints-1] = 0
-
-		spData.BeaconLevel = 0
-		for i := 32; i <= 133; i++ { // beacon center is 103
-			spData.BeaconLevel += spData.Yp[i]
+		spData.NoiseFloor = noiseFloor(levels)
+		for i, level := range levels {
+			spData.Db[i] = level - spData.NoiseFloor
 		}
-		spData.BeaconLevel = spData.BeaconLevel / 103
-		// qLog.Info("beacon level %v : Yp[i] %v", spData.BeaconLevel, spData.Yp[103])
-
-		ch <- spData
-	}
-
-}
 
-// TODO: move to rxControl.go
-/*****************************************************************
-* SPECTRUM & CALIBRARTION MARKERS
-*****************************************************************/
+		process()
 
-var (
-	// TODO: calculatee a mathematical values
-	const_frequencyCentre = map[string]float32{
-		"10491.50 / 00": 103,
-		"10492.75 / 01": 230,
-		"10493.00 / 02": 256,
-		"10493.25 / 03": 281,
-		"10493.50 / 04": 307,
-		"10493.75 / 05": 332,
-		"10494.00 / 06": 358,
-		"10494.25 / 07": 383,
-		"10494.50 / 08": 409,
-		"10494.75 / 09": 434,
-		"10495.00 / 10": 460,
-		"10495.25 / 11": 485,
-		"10495.50 / 12": 511,
-		"10495.75 / 13": 536,
-		"10496.00 / 14": 562,
-		"10496.25 / 15": 588,
-		"10496.50 / 16": 613,
-		"10496.75 / 17": 639,
-		"10497.00 / 18": 664,
-		"10497.25 / 19": 690,
-		"10497.50 / 20": 715,
-		"10497.75 / 21": 741,
-		"10490.00 / 22": 767,
-		"10498.25 / 23": 792,
-		"10498.50 / 24": 818,
-		"10498.75 / 25": 843,
-		"10499.00 / 26": 869,
-		"10499.25 / 27": 894,
-	}
-
-	// TODO: calculatee a mathematical values
-	const_symbolRateWidth = map[string]float32{
-		"2000": 20,
-		"1500": 15,
-		"1000": 10,
-		"500":  8,
-		"333":  5,
-		"250":  4,
-		"125":  3,
-		"66":   2,
-		"33":   1.5,
-	}
-)
-
-// Returns frequency and bandWidth Markers as float32
-func getMarkers(frequency, symbolRate string) (float32, float32) {
-	centre := const_frequencyCentre[frequency] / 9.18 // 9.18 is a temporary kludge
-	width := const_symbolRateWidth[symbolRate]
-	return centre, width
-}
-
-// TODO: implement CalibratetionPoints()
-/*
-func CalibratetionPoints() {
-	var yp [918]float32
-
-	for _, v := range CalibrationMarkerWidth {
-		// yp[v] = 100
-		qLog.Info("CalibratetionPoints %v", v)
-	}
+		for i, db := range spData.Db {
+			spData.Yp[i] = DbToY(db)
+		}
+		spData.Yp[0] = 0
+		spData.Yp[numPoints-1] = 0
 
-	for i, v := range yp {
-		qLog.Info("CalibratetionPoints %v  %v", i, v)
-	}
+		now := time.Now()
+		frameReceived(now)
+		trackBeacon(now)
 
-}
-*/
+		if frames++; frames == kVerifyAfterFrames {
+			VerifyCalibration()
+		}
 
-/*
-func readCalibrationData(ch chan SpData) {
-	qLog.Info("Spectrun calibration running...")
-	for {
-		spData.Yp[0] = 0
-		for i := 1; i < numPoints-2; i++ {
-			spData.Yp[i] = rand.Float32() * 50.0
+		select {
+		case ch <- frame():
+		case <-wsDone:
+			return nil
 		}
-		spData.Yp[numPoints-1] = 0
-		spData.BeaconLevel = rand.Float32() * 100
-		ch <- spData
-		time.Sleep(3 * time.Millisecond)
 	}
+
 }
-*/
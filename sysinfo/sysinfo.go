@@ -0,0 +1,293 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package sysinfo
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"q100receiver-bookworm/lmClient"
+	"q100receiver-bookworm/spectrumClient"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ea7kir/qLog"
+)
+
+// BEGIN API ****************************************************
+
+type (
+	SiConfig struct {
+		Version        string // set at build time, eg. with -ldflags "-X main.version=v1.2"
+		LogFile        string
+		LongmyndFolder string // a git clone, to find the longmynd version
+	}
+	// A program started by lmClient
+	Program struct {
+		Binary  string
+		Version string
+		Pid     int // zero when not running
+	}
+	// What we ask for first when a problem is reported
+	Info struct {
+		Collected    time.Time
+		Version      string
+		Commit       string // with "+" if built from a modified tree
+		GoVersion    string
+		Started      time.Time // this program
+		Uptime       time.Duration
+		Addresses    []string
+		CpuTemp      float64 // °C
+		HasCpuTemp   bool
+		Throttling   string
+		Longmynd     Program
+		Player       Program
+		Spectrum     spectrumClient.Connection
+		LogFile      string
+		IsComplete   bool // false until the first Refresh has finished
+		IsRefreshing bool
+	}
+)
+
+func Intitialize(cfg SiConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	siCfg = cfg
+	started = time.Now()
+}
+
+// Collects the information in the background, see Latest
+func Refresh() {
+	mu.Lock()
+	defer mu.Unlock()
+	if refreshing {
+		return
+	}
+	refreshing = true
+	go collect()
+}
+
+// Returns the information from the last Refresh
+func Latest() Info {
+	mu.Lock()
+	defer mu.Unlock()
+	info := latest
+	info.IsRefreshing = refreshing
+	return info
+}
+
+// END API ****************************************************
+
+const (
+	kVersionTimeout = 2 * time.Second // waits for a program that prints its version or state
+	kUnknown        = "unknown"
+)
+
+// guarded by mu
+var (
+	mu         sync.Mutex
+	siCfg      SiConfig
+	started    time.Time
+	latest     Info
+	refreshing bool
+)
+
+// Collects the information and sets latest. Called as a go routine from Refresh
+func collect() {
+	mu.Lock()
+	cfg := siCfg
+	info := Info{
+		Version:   cfg.Version,
+		GoVersion: runtime.Version(),
+		Started:   started,
+		LogFile:   cfg.LogFile,
+	}
+	mu.Unlock()
+
+	info.Commit = buildCommit()
+	info.Uptime = systemUptime()
+	info.Addresses = ipAddresses()
+	info.CpuTemp, info.HasCpuTemp = cpuTemperature()
+	info.Throttling = throttling()
+	longmynd, player := lmClient.Processes()
+	info.Longmynd = Program{Binary: longmynd.Binary, Pid: longmynd.Pid, Version: gitVersion(cfg.LongmyndFolder)}
+	info.Player = Program{Binary: player.Binary, Pid: player.Pid, Version: programVersion(player.Binary, player.VersionArgs)}
+	info.Spectrum = spectrumClient.ConnectionState()
+	info.Collected = time.Now()
+	info.IsComplete = true
+
+	mu.Lock()
+	defer mu.Unlock()
+	latest = info
+	refreshing = false
+}
+
+// Returns the VCS revision embedded by go build
+func buildCommit() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return kUnknown
+	}
+	var revision, modified string
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision == "" {
+		return kUnknown
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "+"
+	}
+	return revision
+}
+
+// Returns the time since the system booted
+func systemUptime() time.Duration {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Returns the IP addresses of the interfaces that are up, except loopback
+func ipAddresses() []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		qLog.Warn("Failed to list the network interfaces: %v", err)
+		return nil
+	}
+	var addresses []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLinkLocalUnicast() {
+				addresses = append(addresses, iface.Name+" "+ipnet.IP.String())
+			}
+		}
+	}
+	return addresses
+}
+
+// Returns the CPU temperature in °C, and true if it is known
+func cpuTemperature() (float64, bool) {
+	data, err := os.ReadFile("/sys/class/thermal/thermal_zone0/temp")
+	if err != nil {
+		return 0, false
+	}
+	milli, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return float64(milli) / 1000, true
+}
+
+// the bits reported by vcgencmd get_throttled, now and since boot
+var kThrottledBits = []struct {
+	now, since uint64
+	what       string
+}{
+	{1 << 0, 1 << 16, "under-voltage"},
+	{1 << 1, 1 << 17, "frequency capped"},
+	{1 << 2, 1 << 18, "throttled"},
+	{1 << 3, 1 << 19, "soft temperature limit"},
+}
+
+// Returns the throttling state from vcgencmd, ie. "none", or what is happening now and what has happened since boot
+func throttling() string {
+	out, err := runWithTimeout("/usr/bin/vcgencmd", "get_throttled")
+	if err != nil {
+		return kUnknown
+	}
+	_, hex, ok := strings.Cut(strings.TrimSpace(out), "=0x")
+	if !ok {
+		return kUnknown
+	}
+	bits, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return kUnknown
+	}
+	if bits == 0 {
+		return "none"
+	}
+	var now, since []string
+	for _, b := range kThrottledBits {
+		if bits&b.now != 0 {
+			now = append(now, b.what)
+		}
+		if bits&b.since != 0 {
+			since = append(since, b.what)
+		}
+	}
+	var parts []string
+	if len(now) > 0 {
+		parts = append(parts, "now "+strings.Join(now, ", "))
+	}
+	if len(since) > 0 {
+		parts = append(parts, "since boot "+strings.Join(since, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Returns the first line printed by a program when run with args, or with --version if there are none
+func programVersion(binary string, args []string) string {
+	if len(args) == 0 {
+		args = []string{"--version"}
+	}
+	out, err := runWithTimeout(binary, args...)
+	if err != nil {
+		return kUnknown
+	}
+	line, _, _ := strings.Cut(out, "\n")
+	return strings.TrimSpace(line)
+}
+
+// Returns the version of a git clone, ie. its latest tag or commit
+func gitVersion(folder string) string {
+	if folder == "" {
+		return kUnknown
+	}
+	out, err := runWithTimeout("/usr/bin/git", "-C", folder, "describe", "--tags", "--always", "--dirty")
+	if err != nil {
+		return kUnknown
+	}
+	return strings.TrimSpace(out)
+}
+
+// Returns the output of a command, which is killed after kVersionTimeout
+func runWithTimeout(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kVersionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	return string(out), err
+}